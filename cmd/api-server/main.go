@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
-
+	"os/signal"
+	"syscall"
 	"time"
 
 	"azule.info/calorize/internal/api"
@@ -15,9 +19,9 @@ import (
 	"github.com/google/uuid"
 )
 
-func setupDevUser() (db.UserID, error) {
+func setupDevUser(ctx context.Context, store db.Store) (db.UserID, error) {
 	name := "dev_user"
-	u, err := db.GetUser(name)
+	u, err := store.GetUser(ctx, name)
 	if err != nil {
 		return db.UserID(uuid.Nil), err
 	}
@@ -30,7 +34,7 @@ func setupDevUser() (db.UserID, error) {
 		Email:     "dev@example.com",
 		CreatedAt: time.Now(),
 	}
-	created, err := db.CreateUser(newUser)
+	created, err := store.CreateUser(ctx, newUser)
 	if err != nil {
 		return db.UserID(uuid.Nil), err
 	}
@@ -41,12 +45,29 @@ func main() {
 	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	logger := slog.New(handler)
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	logger.InfoContext(ctx, "Starting API server...")
 
 	slog.SetDefault(logger)
 
-	devUserID, err := setupDevUser()
+	dsn := flag.String("db", os.Getenv("DB_DSN"), "database DSN; postgres:// URLs select PostgreSQL (defaults to $DB_DSN, then "+db.DefaultDSN+")")
+	queryTimeout := flag.Duration("query-timeout", 10*time.Second, "maximum duration of a single database call (0 disables)")
+	maxRecipeDepth := flag.Int("max-recipe-depth", db.DefaultMaxRecipeDepth, "maximum nesting of recipes inside recipes")
+	flag.Parse()
+
+	store, err := db.Open(ctx, db.Config{
+		DSN:            *dsn,
+		QueryTimeout:   *queryTimeout,
+		MaxRecipeDepth: *maxRecipeDepth,
+	})
+	if err != nil {
+		slog.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	devUserID, err := setupDevUser(ctx, store)
 	if err != nil {
 		slog.Error("failed to setup dev user", "error", err)
 		os.Exit(1)
@@ -55,8 +76,8 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	auth.RegisterAuthPaths(mux)
-	api.RegisterApiPaths(mux)
+	auth.RegisterAuthPaths(mux, store)
+	api.RegisterApiPaths(mux, store)
 
 	mux.Handle("GET /hello/{name}", http.HandlerFunc(helloHandler))
 
@@ -72,7 +93,7 @@ func main() {
 		}
 		finalHandler = middleware.Logger(devAuthMiddleware(mux))
 	} else {
-		finalHandler = middleware.Logger(middleware.RequireAuth(mux))
+		finalHandler = middleware.Logger(middleware.RequireAuth(store, mux))
 	}
 
 	// 4. Start the server
@@ -83,8 +104,26 @@ func main() {
 	serverAddr := ":" + port
 	slog.Info("server starting", "addr", serverAddr)
 
-	err = http.ListenAndServe(serverAddr, finalHandler)
-	if err != nil {
+	// Request contexts derive from ctx, so a shutdown signal cancels in-flight
+	// database calls as well as stopping the listener.
+	server := &http.Server{
+		Addr:        serverAddr,
+		Handler:     finalHandler,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		slog.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown failed", "error", err)
+		}
+	}()
+
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("server failed", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"azule.info/calorize/internal/db"
+)
+
+// Foods are visible to their creator and, once public, to everyone; only the
+// creator may change them. Foods a caller cannot see are reported as 404, as
+// if they did not exist, and visible foods they cannot change as 403.
+
+// authorizeFood checks the caller may view or edit foodID, writing the error
+// response and returning false if not.
+func (h *handlers) authorizeFood(w http.ResponseWriter, r *http.Request, userID db.UserID, foodID db.FoodID, access db.FoodAccess) bool {
+	err := h.store.AuthorizeFood(r.Context(), userID, foodID, access)
+	if err != nil {
+		writeAccessError(w, err, "Failed to check food access")
+		return false
+	}
+	return true
+}
+
+// writeAccessError writes the 404 or 403 for a food access error, and a 500
+// with msg for anything else.
+func writeAccessError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, db.ErrFoodNotFound):
+		http.Error(w, "Food not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		slog.Error(msg, "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"azule.info/calorize/internal/auth"
+	"azule.info/calorize/internal/db"
+	"github.com/google/uuid"
+)
+
+// newTestServer serves the API from a fresh SQLite database.
+func newTestServer(t *testing.T) (*http.ServeMux, db.Store) {
+	t.Helper()
+	store, err := db.Open(t.Context(), db.Config{DSN: "file:" + filepath.Join(t.TempDir(), "test.db") + "?_fk=1&_journal=WAL&_busy_timeout=5000"})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	mux := http.NewServeMux()
+	RegisterApiPaths(mux, store)
+	return mux, store
+}
+
+func createUser(t *testing.T, store db.Store, name string) db.UserID {
+	t.Helper()
+	user, err := store.CreateUser(t.Context(), db.User{Name: name, Email: name + "@example.com", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	return user.ID
+}
+
+func createFood(t *testing.T, store db.Store, userID db.UserID, name string, public bool) db.FoodID {
+	t.Helper()
+	food, err := store.CreateFood(t.Context(), db.Food{
+		CreatorID:         userID,
+		Name:              name,
+		Public:            public,
+		MeasurementUnit:   "g",
+		MeasurementAmount: 100,
+		Calories:          100,
+	})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	return food.ID
+}
+
+// serve sends a request as userID and returns the response status.
+func serve(mux *http.ServeMux, userID db.UserID, method, path, body string) int {
+	return send(mux, userID, method, path, body, nil).Code
+}
+
+// send sends a request with header as userID and returns the response.
+func send(mux *http.ServeMux, userID db.UserID, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserIDContextKey, userID))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestFoodAccess(t *testing.T) {
+	mux, store := newTestServer(t)
+	owner := createUser(t, store, "owner")
+	other := createUser(t, store, "other")
+	private := createFood(t, store, owner, "Private", false)
+	public := createFood(t, store, owner, "Public", true)
+
+	food := func(id db.FoodID) string { return "/foods/" + uuid.UUID(id).String() }
+	update := `{"name": "Renamed", "measurement_unit": "g", "measurement_amount": 100, "calories": 50, "base_version": 1}`
+	portion := `{"name": "Bowl", "amount": 250}`
+
+	tests := []struct {
+		name   string
+		user   db.UserID
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"owner views private", owner, "GET", food(private), "", http.StatusOK},
+		{"other views public", other, "GET", food(public), "", http.StatusOK},
+		{"other views private", other, "GET", food(private), "", http.StatusNotFound},
+		{"other views missing", other, "GET", food(db.FoodID(uuid.New())), "", http.StatusNotFound},
+		{"other lists private dependents", other, "GET", food(private) + "/dependents", "", http.StatusNotFound},
+		{"other lists private portions", other, "GET", food(private) + "/portions", "", http.StatusNotFound},
+		{"other lists public portions", other, "GET", food(public) + "/portions", "", http.StatusOK},
+
+		{"other lists public versions", other, "GET", food(public) + "/versions", "", http.StatusOK},
+		{"other lists private versions", other, "GET", food(private) + "/versions", "", http.StatusNotFound},
+		{"other diffs private", other, "GET", food(private) + "/diff?from=1&to=1", "", http.StatusNotFound},
+		{"other diffs unknown version", other, "GET", food(public) + "/diff?from=v1&to=v9", "", http.StatusNotFound},
+		{"other diffs bad version", other, "GET", food(public) + "/diff?from=first", "", http.StatusBadRequest},
+		{"other restores public", other, "POST", food(public) + "/restore?version=1", "", http.StatusForbidden},
+
+		{"other logs private", other, "POST", "/logs", `{"food_id": "` + uuid.UUID(private).String() + `", "amount": 100}`, http.StatusNotFound},
+		{"other views private after logging attempt", other, "GET", food(private), "", http.StatusNotFound},
+		{"other logs public", other, "POST", "/logs", `{"food_id": "` + uuid.UUID(public).String() + `", "amount": 100}`, http.StatusOK},
+
+		{"other forks private", other, "POST", food(private) + "/fork", "", http.StatusNotFound},
+		{"other forks public", other, "POST", food(public) + "/fork", "", http.StatusOK},
+
+		{"other updates public", other, "PUT", food(public), update, http.StatusForbidden},
+		{"other updates private", other, "PUT", food(private), update, http.StatusNotFound},
+		{"other adds portion to public", other, "POST", food(public) + "/portions", portion, http.StatusForbidden},
+		{"other adds portion to private", other, "POST", food(private) + "/portions", portion, http.StatusNotFound},
+		{"other deletes public", other, "DELETE", food(public), "", http.StatusForbidden},
+		{"other deletes private", other, "DELETE", food(private), "", http.StatusNotFound},
+		{"other publishes private", other, "POST", food(private) + "/publish", "", http.StatusNotFound},
+		{"other unpublishes public", other, "POST", food(public) + "/unpublish", "", http.StatusForbidden},
+		{"other lists with bad scope", other, "GET", "/foods?scope=friends", "", http.StatusBadRequest},
+
+		{"owner adds portion", owner, "POST", food(private) + "/portions", portion, http.StatusOK},
+		{"owner deletes missing portion", owner, "DELETE", food(private) + "/portions/" + uuid.New().String(), "", http.StatusNotFound},
+		{"owner updates", owner, "PUT", food(private), update, http.StatusOK},
+		{"owner adds portion to old version", owner, "POST", food(private) + "/portions", portion, http.StatusConflict},
+		{"owner diffs", owner, "GET", food(private) + "/diff", "", http.StatusOK},
+		{"owner restores without version", owner, "POST", food(private) + "/restore", "", http.StatusBadRequest},
+		{"owner restores", owner, "POST", food(private) + "/restore?version=1", "", http.StatusOK},
+		{"owner publishes without macros", owner, "POST", food(private) + "/publish", "", http.StatusUnprocessableEntity},
+		{"owner unpublishes", owner, "POST", food(public) + "/unpublish", "", http.StatusOK},
+		{"owner deletes", owner, "DELETE", food(public), "", http.StatusNoContent},
+		{"owner deletes again", owner, "DELETE", food(public), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := serve(mux, tt.user, tt.method, tt.path, tt.body); got != tt.want {
+			t.Errorf("%s: %s %s: expected %d, got %d", tt.name, tt.method, tt.path, tt.want, got)
+		}
+	}
+
+	// The public food was never versioned under the other user
+	versions, err := store.GetFoodVersions(t.Context(), owner, public)
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	for _, v := range versions {
+		if v.CreatorID != owner {
+			t.Errorf("Expected every version created by the owner, got %v", v.CreatorID)
+		}
+	}
+}
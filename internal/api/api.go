@@ -2,13 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"azule.info/calorize/internal/auth"
 	"azule.info/calorize/internal/db"
+	"azule.info/calorize/internal/units"
 	"github.com/google/uuid"
 )
 
@@ -24,89 +28,225 @@ func getUserID(r *http.Request) (db.UserID, error) {
 	return uid, nil
 }
 
-func RegisterApiPaths(mux *http.ServeMux) {
-	RegisterLogsPaths(mux)
-	RegisterFoodsPaths(mux)
-	RegisterStatsPaths(mux)
+// pageOptions reads the limit, cursor and sort query parameters shared by
+// the list endpoints.
+func pageOptions(r *http.Request) (db.PageOptions, error) {
+	q := r.URL.Query()
+	opts := db.PageOptions{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("invalid limit: %s", l)
+		}
+		opts.Limit = limit
+	}
+	return opts, nil
+}
+
+// writeListError maps an error from a paginated listing to a response.
+func writeListError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, db.ErrInvalidCursor) || errors.Is(err, db.ErrInvalidSort) || errors.Is(err, db.ErrInvalidScope) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Error("listing failed", "error", err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+// handlers carries the dependencies shared by every API handler.
+type handlers struct {
+	store db.Store
+}
+
+func RegisterApiPaths(mux *http.ServeMux, store db.Store) {
+	RegisterLogsPaths(mux, store)
+	RegisterFoodsPaths(mux, store)
+	RegisterStatsPaths(mux, store)
+	RegisterProfilePaths(mux, store)
+	RegisterGoalsPaths(mux, store)
+	RegisterMeasurementsPaths(mux, store)
 }
 
 // ### Foods
 // - GET /foods
-//     - Returns list of current versions
+//     - Returns list of current versions as { items: [], next_cursor }
+//     - Query Params: ?limit=&cursor=&sort={name,created_at,most_logged}
+//     - ?q= searches name, brand and nutrients instead, matching each word as a prefix
+//       and ranking the caller's own and recently logged foods higher
+//       (a search's next_cursor only continues the same q; 400 otherwise)
+//     - ?scope={mine,public,all} limits the list or search to the caller's own foods or
+//       to public ones (default all, both)
+//     - Recipe macros are computed from the ingredients, as in GET /foods/{id}
 // - POST /foods
 //     - Create new food/recipe
-//     - Payload: { name, calories, protein, carbs, fat, type, measurement_unit, measurement_amount, nutrients: [], ingredients: {} }
+//     - Payload: { name, brand, calories, protein, carbs, fat, type, measurement_unit, measurement_amount, yield_weight, servings, density, nutrients: [], ingredients: {} }
+//     - ingredients may also be [{ food_id, amount, unit, follow_latest }]; follow_latest items track the ingredient's current version
+//     - Ingredients must be foods the caller can see, or ones earlier versions already used (400 otherwise)
 // - GET /foods/{id}
 //     - Returns details including sub-ingredients if recipe
+//     - Recipe macros and nutrients are computed from the ingredients
+//     - Recipes with a yield also report raw_weight, cooking_loss, per_serving and per_100g
+//     - The ETag header is the version number, e.g. "3"
 // - PUT /foods/{id}
 //     - Updates a food by creating a NEW Version
-//     - Payload: Same as POST
+//     - Payload: Same as POST, plus base_version
+//     - The version the edit is based on is required, as an If-Match header with the
+//       ETag from GET /foods/{id} (e.g. "3") or as base_version; If-Match wins if both
+//       are given. 428 if it is missing, 409 if it is no longer the current version
+//     - 422 if recipes following the food's latest version could no longer measure it, e.g.
+//       cups of a food now measured in grams without a density
+//     - ?cascade=true also creates new versions of the caller's recipes pinned to an older version,
+//       returned in "cascaded"
+//       (a recipe pinning several older versions gets one new version, with those items merged)
+//     - The update is saved even if the cascade fails; the recipes updated before the failure are
+//       still returned in "cascaded" and the failure in "cascade_error"
+// - POST /foods/{id}/fork
+//     - Copies a food version the caller can see, with its nutrients, ingredients and
+//       portions, into a new private family owned by the caller
+//     - The copy records source_family_id and source_version, kept by its later versions
+// - POST /foods/{id}/publish
+//     - Makes every version of the caller's food public
+//     - 422 unless it has a name and measurement, its calories roughly match its macros
+//       (4/4/9 kcal per gram) and, for recipes, every ingredient is public
+// - POST /foods/{id}/unpublish
+//     - Makes the food private again; users who logged it still see the versions they logged
+// - GET /foods/{id}/dependents
+//     - Lists current recipes using any version of this food, flagging pinned ones that are outdated
+// - /foods/{id}/portions
+//     - Named portions, see portions.go
+// - /foods/{id}/versions, /foods/{id}/diff and /foods/{id}/restore
+//     - Version history, see versions.go
 // - DELETE /foods/{id}
 //     - Soft delete
+//
+// Foods are visible to their creator and, when public, to everyone. Only the
+// creator may update or delete a food or change its portions (403 otherwise);
+// other users' private foods are reported as 404 on every path. A food only
+// becomes public or private through publish and unpublish.
 
-// { name, calories, protein, carbs, fat, type, measurement_unit, measurement_amount, nutrients: [], ingredients: {} }
+// { name, brand, calories, protein, carbs, fat, type, measurement_unit, measurement_amount, yield_weight, servings, density, nutrients: [], ingredients: {} }
 type createFoodRequest struct {
-	Name              string             `json:"name"`
-	Calories          float64            `json:"calories"`
-	Protein           float64            `json:"protein"`
-	Carbs             float64            `json:"carbs"`
-	Fat               float64            `json:"fat"`
-	Type              string             `json:"type"`
-	MeasurementUnit   string             `json:"measurement_unit"`
-	MeasurementAmount float64            `json:"measurement_amount"`
-	Nutrients         []db.FoodNutrient  `json:"nutrients"`
-	Ingredients       map[string]float64 `json:"ingredients"`
-}
-
-func RegisterFoodsPaths(mux *http.ServeMux) {
-	mux.HandleFunc("GET /foods", getFoodsHandler)
-	mux.HandleFunc("POST /foods", createFoodHandler)
-	mux.HandleFunc("GET /foods/{id}", getFoodHandler)
-	mux.HandleFunc("PUT /foods/{id}", updateFoodHandler)
-	mux.HandleFunc("DELETE /foods/{id}", deleteFoodHandler)
-}
-
-func getFoodsHandler(w http.ResponseWriter, r *http.Request) {
+	Name              string            `json:"name"`
+	Brand             string            `json:"brand"`
+	Calories          float64           `json:"calories"`
+	Protein           float64           `json:"protein"`
+	Carbs             float64           `json:"carbs"`
+	Fat               float64           `json:"fat"`
+	Type              string            `json:"type"`
+	MeasurementUnit   string            `json:"measurement_unit"`
+	MeasurementAmount float64           `json:"measurement_amount"`
+	YieldWeight       float64           `json:"yield_weight"`
+	Servings          float64           `json:"servings"`
+	Density           float64           `json:"density"`
+	Nutrients         []db.FoodNutrient `json:"nutrients"`
+	Ingredients       ingredientList    `json:"ingredients"`
+}
+
+// Same as createFoodRequest, plus { base_version }
+type updateFoodRequest struct {
+	createFoodRequest
+	BaseVersion int `json:"base_version"`
+}
+
+// ingredientList accepts either the original {"<food id>": amount} object,
+// which pins each ingredient to the given version, or a list of
+// { food_id, amount, unit, follow_latest } items.
+type ingredientList []db.RecipeItems
+
+func (l *ingredientList) UnmarshalJSON(data []byte) error {
+	var byID map[string]float64
+	if err := json.Unmarshal(data, &byID); err == nil {
+		for id, amount := range byID {
+			foodID, err := uuid.Parse(id)
+			if err != nil {
+				return fmt.Errorf("invalid ingredient id %q: %w", id, err)
+			}
+			*l = append(*l, db.RecipeItems{IngredientID: db.FoodID(foodID), Amount: amount})
+		}
+		return nil
+	}
+
+	var items []struct {
+		FoodID       db.FoodID `json:"food_id"`
+		Amount       float64   `json:"amount"`
+		Unit         string    `json:"unit"`
+		FollowLatest bool      `json:"follow_latest"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		*l = append(*l, db.RecipeItems{
+			IngredientID: item.FoodID,
+			Amount:       item.Amount,
+			Unit:         item.Unit,
+			FollowLatest: item.FollowLatest,
+		})
+	}
+	return nil
+}
+
+func RegisterFoodsPaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /foods", h.getFoodsHandler)
+	mux.HandleFunc("POST /foods", h.createFoodHandler)
+	mux.HandleFunc("GET /foods/{id}", h.getFoodHandler)
+	mux.HandleFunc("PUT /foods/{id}", h.updateFoodHandler)
+	mux.HandleFunc("DELETE /foods/{id}", h.deleteFoodHandler)
+	mux.HandleFunc("POST /foods/{id}/fork", h.forkFoodHandler)
+	mux.HandleFunc("POST /foods/{id}/publish", h.publishFoodHandler(true))
+	mux.HandleFunc("POST /foods/{id}/unpublish", h.publishFoodHandler(false))
+	mux.HandleFunc("GET /foods/{id}/dependents", h.getFoodDependentsHandler)
+	mux.HandleFunc("GET /foods/{id}/versions", h.getFoodVersionsHandler)
+	mux.HandleFunc("GET /foods/{id}/diff", h.diffFoodVersionsHandler)
+	mux.HandleFunc("POST /foods/{id}/restore", h.restoreFoodVersionHandler)
+	mux.HandleFunc("GET /foods/{id}/portions", h.getFoodPortionsHandler)
+	mux.HandleFunc("POST /foods/{id}/portions", h.createFoodPortionHandler)
+	mux.HandleFunc("PUT /foods/{id}/portions/{portionID}", h.updateFoodPortionHandler)
+	mux.HandleFunc("DELETE /foods/{id}/portions/{portionID}", h.deleteFoodPortionHandler)
+}
+
+func (h *handlers) getFoodsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	foods, err := db.GetFoods(userID)
+	opts, err := pageOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scope := db.FoodScope(r.URL.Query().Get("scope"))
+	var page db.Page[db.Food]
+	if q := r.URL.Query().Get("q"); q != "" {
+		page, err = h.store.SearchFoods(r.Context(), userID, q, scope, opts)
+	} else {
+		page, err = h.store.GetFoods(r.Context(), userID, scope, opts)
+	}
 	if err != nil {
-		http.Error(w, "Failed to get foods", http.StatusInternalServerError)
+		writeListError(w, err, "Failed to get foods")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(foods)
+	json.NewEncoder(w).Encode(page)
 }
 
-func createFoodHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) createFoodHandler(w http.ResponseWriter, r *http.Request) {
 	var req createFoodRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	var ingredients []db.RecipeItems
-	for id, amount := range req.Ingredients {
-		foodID, err := uuid.Parse(id)
-		if err != nil {
-			continue // or handle error
-		}
-		ingredients = append(ingredients, db.RecipeItems{
-			IngredientID: db.FoodID(foodID),
-			Amount:       amount,
-		})
-	}
-
 	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	food, err := db.CreateFood(db.Food{
+	food, err := h.store.CreateFood(r.Context(), db.Food{
 		CreatorID:         userID,
 		Name:              req.Name,
+		Brand:             req.Brand,
 		Calories:          req.Calories,
 		Protein:           req.Protein,
 		Carbs:             req.Carbs,
@@ -114,24 +254,58 @@ func createFoodHandler(w http.ResponseWriter, r *http.Request) {
 		Type:              req.Type,
 		MeasurementUnit:   req.MeasurementUnit,
 		MeasurementAmount: req.MeasurementAmount,
-		Ingredients:       ingredients,
+		YieldWeight:       req.YieldWeight,
+		Servings:          req.Servings,
+		Density:           req.Density,
+		Nutrients:         req.Nutrients,
+		Ingredients:       req.Ingredients,
 	})
 	if err != nil {
-		http.Error(w, "Failed to create food", http.StatusInternalServerError)
+		writeFoodError(w, err, "Failed to create food")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(food)
 }
 
-func getFoodHandler(w http.ResponseWriter, r *http.Request) {
+// writeFoodError maps an error from creating or updating a food to a response.
+// Problems with the submitted ingredients are the client's to fix.
+func writeFoodError(w http.ResponseWriter, err error, msg string) {
+	var cycle *db.RecipeCycleError
+	var depth *db.RecipeDepthError
+	switch {
+	case errors.Is(err, db.ErrIngredientNotFound), errors.Is(err, db.ErrInvalidYield),
+		errors.Is(err, db.ErrInvalidDensity), errors.Is(err, units.ErrIncompatible):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &cycle), errors.As(err, &depth), errors.Is(err, db.ErrNotPublishable),
+		errors.Is(err, db.ErrBreaksRecipes):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, db.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrFoodNotFound), errors.Is(err, db.ErrForbidden):
+		writeAccessError(w, err, msg)
+	default:
+		slog.Error("food write failed", "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) getFoodHandler(w http.ResponseWriter, r *http.Request) {
 	foodIDString := r.PathValue("id")
 	foodID, err := uuid.Parse(foodIDString)
 	if err != nil {
 		http.Error(w, "Invalid food ID", http.StatusBadRequest)
 		return
 	}
-	food, err := db.GetFood(db.FoodID(foodID))
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.ViewFood) {
+		return
+	}
+	food, err := h.store.GetFood(r.Context(), db.FoodID(foodID))
 	if err != nil {
 		http.Error(w, "Failed to get food", http.StatusInternalServerError)
 		return
@@ -140,42 +314,73 @@ func getFoodHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Food not found", http.StatusNotFound)
 		return
 	}
+	w.Header().Set("ETag", etag(food))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(food)
 }
 
-func updateFoodHandler(w http.ResponseWriter, r *http.Request) {
+// etag is the entity tag of a food version: its version number, which
+// PUT /foods/{id} takes back in If-Match.
+func etag(f *db.Food) string {
+	return `"` + strconv.Itoa(f.Version) + `"`
+}
+
+// parseETag reads the version number from an If-Match header written by
+// etag. Weak tags are accepted, as the version number is all that matters.
+func parseETag(match string) (int, error) {
+	tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(match), "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match: %s", match)
+	}
+	return version, nil
+}
+
+func (h *handlers) updateFoodHandler(w http.ResponseWriter, r *http.Request) {
 	foodIDString := r.PathValue("id")
 	foodID, err := uuid.Parse(foodIDString)
 	if err != nil {
 		http.Error(w, "Invalid food ID", http.StatusBadRequest)
 		return
 	}
-	var req createFoodRequest
+	var req updateFoodRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	var ingredients []db.RecipeItems
-	for id, amount := range req.Ingredients {
-		foodID, err := uuid.Parse(id)
+	baseVersion := req.BaseVersion
+	if match := r.Header.Get("If-Match"); match != "" {
+		baseVersion, err = parseETag(match)
 		if err != nil {
-			continue
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		ingredients = append(ingredients, db.RecipeItems{
-			IngredientID: db.FoodID(foodID),
-			Amount:       amount,
-		})
 	}
-
 	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	food, err := db.UpdateFood(db.FoodID(foodID), db.Food{
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.EditFood) {
+		return
+	}
+	if baseVersion < 1 {
+		http.Error(w, "If-Match or base_version is required", http.StatusPreconditionRequired)
+		return
+	}
+	base, err := h.store.GetFoodVersion(r.Context(), db.FoodID(foodID), baseVersion)
+	if err != nil {
+		writeFoodError(w, err, "Failed to update food")
+		return
+	}
+	if base == nil {
+		http.Error(w, fmt.Sprintf("%v: version %d", db.ErrVersionConflict, baseVersion), http.StatusConflict)
+		return
+	}
+	food, err := h.store.UpdateFood(r.Context(), base.ID, db.Food{
 		CreatorID:         userID,
 		Name:              req.Name,
+		Brand:             req.Brand,
 		Calories:          req.Calories,
 		Protein:           req.Protein,
 		Carbs:             req.Carbs,
@@ -183,26 +388,122 @@ func updateFoodHandler(w http.ResponseWriter, r *http.Request) {
 		Type:              req.Type,
 		MeasurementUnit:   req.MeasurementUnit,
 		MeasurementAmount: req.MeasurementAmount,
-		Ingredients:       ingredients,
+		YieldWeight:       req.YieldWeight,
+		Servings:          req.Servings,
+		Density:           req.Density,
+		Nutrients:         req.Nutrients,
+		Ingredients:       req.Ingredients,
 	})
 	if err != nil {
-		http.Error(w, "Failed to update food", http.StatusInternalServerError)
+		writeFoodError(w, err, "Failed to update food")
+		return
+	}
+
+	w.Header().Set("ETag", etag(food))
+	resp := updateFoodResponse{Food: food}
+	if r.URL.Query().Get("cascade") == "true" {
+		// The update is saved by now, so a failed cascade is reported
+		// alongside it rather than failing the request.
+		resp.Cascaded, err = h.store.CascadeFoodUpdate(r.Context(), userID, food.ID)
+		if err != nil {
+			slog.Warn("food update cascade failed", "error", err, "id", uuid.UUID(food.ID))
+			resp.CascadeError = err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// updateFoodResponse is the new food version, plus any recipe versions
+// created by ?cascade=true and why the cascade stopped, if it did.
+type updateFoodResponse struct {
+	*db.Food
+	Cascaded     []db.Food `json:"cascaded,omitempty"`
+	CascadeError string    `json:"cascade_error,omitempty"`
+}
+
+func (h *handlers) forkFoodHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	food, err := h.store.ForkFood(r.Context(), userID, db.FoodID(foodID))
+	if err != nil {
+		writeFoodError(w, err, "Failed to fork food")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(food)
 }
 
-func deleteFoodHandler(w http.ResponseWriter, r *http.Request) {
+// publishFoodHandler returns the handler that publishes the food when public
+// is true and unpublishes it otherwise.
+func (h *handlers) publishFoodHandler(public bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		foodID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid food ID", http.StatusBadRequest)
+			return
+		}
+		userID, err := getUserID(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		food, err := h.store.SetFoodPublic(r.Context(), userID, db.FoodID(foodID), public)
+		if err != nil {
+			writeFoodError(w, err, "Failed to change food visibility")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(food)
+	}
+}
+
+func (h *handlers) getFoodDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.ViewFood) {
+		return
+	}
+	dependents, err := h.store.GetRecipeDependents(r.Context(), userID, db.FoodID(foodID))
+	if err != nil {
+		slog.Error("failed to list dependents", "error", err, "id", foodID)
+		http.Error(w, "Failed to get dependents", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dependents)
+}
+
+func (h *handlers) deleteFoodHandler(w http.ResponseWriter, r *http.Request) {
 	foodIDString := r.PathValue("id")
 	foodID, err := uuid.Parse(foodIDString)
 	if err != nil {
 		http.Error(w, "Invalid food ID", http.StatusBadRequest)
 		return
 	}
-	if err := db.DeleteFood(db.FoodID(foodID)); err != nil {
-		slog.Error("failed to delete food", "error", err, "id", foodID)
-		http.Error(w, "Failed to delete food", http.StatusInternalServerError)
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store.DeleteFood(r.Context(), userID, db.FoodID(foodID)); err != nil {
+		writeAccessError(w, err, "Failed to delete food")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -210,76 +511,376 @@ func deleteFoodHandler(w http.ResponseWriter, r *http.Request) {
 
 // ### Stats
 // - GET /stats
-//     - Query Params: ?period={day,week,month}&date=YYYY-MM-DD
-//     - Returns aggregated macros and total calories
+//     - Query Params: ?period={day,week,month,year,last_7_days,last_30_days}&date=YYYY-MM-DD
+//       (any last_N_days up to 366 works; rolling windows end with date)
+//       or ?from=YYYY-MM-DD&to=YYYY-MM-DD (inclusive; to defaults to today)
+//     - Weeks start on the profile's week_start (Monday by default)
+//     - Returns aggregated macros and total calories over date..end_date
+//     - Also returns nutrients: [{ name, amount, unit }], merging a nutrient stored
+//       in convertible units (mg and µg) into the smallest of them
+//     - ?nutrients=Vitamin C,Iron limits the nutrient totals to those names
+//     - Also returns meals: [{ meal_tag, calories, protein, carbs, fat, days, average }],
+//       where days counts the days the meal was logged and average is per such day
+//     - With goals set, also returns goal: { targets: [{ nutrient, unit, min, max, actual,
+//       remaining, under, over, met }], days_tracked, days_met, adherence }
+//       (bounds are summed over the period's days up to today, or averaged for % targets;
+//       adherence is the percentage of logged days that met every target)
+// - GET /stats/series
+//     - Query Params: ?from=YYYY-MM-DD&to=YYYY-MM-DD&bucket={day,week,month}
+//       (or period and date as for /stats; bucket defaults to day)
+//     - Returns one stats object per bucket, including empty ones; the first and
+//       last buckets are clipped to the range
+//     - Accepts ?nutrients= as for /stats
+//     - Periods are bounded in the profile time zone, or ?tz= (IANA name) if given
+// - GET /stats/energy
+//     - Estimates total daily energy expenditure (TDEE) as the average intake minus
+//       the energy equivalent (7700 kcal/kg) of the weight trend's change
+//     - Query Params: the range as for /stats, defaulting to the 28 days up to yesterday
+//     - Returns { date, end_date, days, logged_days, incomplete_days, intake, trend_start,
+//       trend_end, weekly_change, tdee, target }; weights are in kg
+//     - Days without logs are listed in incomplete_days and left out of intake
+//       instead of counting as zero
+//     - tdee needs logged days and weight readings on two different days
+//     - ?rate=-0.5 (per week; &rate_unit=lb, default kg) adds target: { rate, calories },
+//       the daily intake expected to change weight at that rate
 
-func RegisterStatsPaths(mux *http.ServeMux) {
-	mux.HandleFunc("GET /stats", getStatsHandler)
+func RegisterStatsPaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /stats", h.getStatsHandler)
+	mux.HandleFunc("GET /stats/series", h.getStatsSeriesHandler)
+	mux.HandleFunc("GET /stats/energy", h.getEnergyHandler)
 }
 
-func getStatsHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) getStatsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	dateStr := r.URL.Query().Get("date")
-	date := time.Now()
-	if dateStr != "" {
-		parsed, err := time.Parse("2006-01-02", dateStr)
-		if err == nil {
-			date = parsed
-		}
+	cal, err := h.userCalendar(r, userID)
+	if err != nil {
+		writeProfileError(w, err, "Failed to get stats")
+		return
+	}
+	period, err := statsPeriod(r, cal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	stats, err := db.GetStats(userID, r.URL.Query().Get("period"), date)
+	stats, err := h.store.GetStats(r.Context(), userID, period)
 	if err != nil {
+		if errors.Is(err, units.ErrIncompatible) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		http.Error(w, "Failed to get stats", http.StatusInternalServerError)
 		return
 	}
+	keepNutrients(&stats, nutrientAllowList(r))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+func (h *handlers) getStatsSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cal, err := h.userCalendar(r, userID)
+	if err != nil {
+		writeProfileError(w, err, "Failed to get stats")
+		return
+	}
+	period, err := statsPeriod(r, cal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	buckets, err := period.Buckets(bucket, cal.weekStart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.store.GetStatsSeries(r.Context(), userID, buckets)
+	if err != nil {
+		if errors.Is(err, units.ErrIncompatible) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to get stats series", "error", err)
+		http.Error(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+	allow := nutrientAllowList(r)
+	for i := range series {
+		keepNutrients(&series[i], allow)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+func (h *handlers) getEnergyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cal, err := h.userCalendar(r, userID)
+	if err != nil {
+		writeProfileError(w, err, "Failed to get energy estimate")
+		return
+	}
+	var period db.Period
+	q := r.URL.Query()
+	if q.Get("period") == "" && q.Get("from") == "" && q.Get("to") == "" {
+		yesterday := time.Now().In(cal.loc).AddDate(0, 0, -1)
+		period, err = db.NewPeriod("last_28_days", yesterday, cal.weekStart)
+	} else {
+		period, err = statsPeriod(r, cal)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var rate *float64
+	if param := q.Get("rate"); param != "" {
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid rate: %s", param), http.StatusBadRequest)
+			return
+		}
+		if unit := q.Get("rate_unit"); unit != "" {
+			if units.Parse(unit).Dimension != units.Mass {
+				http.Error(w, fmt.Sprintf("invalid rate_unit: %s", unit), http.StatusBadRequest)
+				return
+			}
+			value, _ = units.Convert(value, unit, "kg", 0)
+		}
+		rate = &value
+	}
+
+	estimate, err := h.store.GetEnergyEstimate(r.Context(), userID, period)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPeriod) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, units.ErrIncompatible) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to estimate energy", "error", err)
+		http.Error(w, "Failed to get energy estimate", http.StatusInternalServerError)
+		return
+	}
+	if rate != nil {
+		estimate.SuggestTarget(*rate)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// nutrientAllowList reads the comma separated ?nutrients= parameter. A nil
+// list allows every nutrient.
+func nutrientAllowList(r *http.Request) []string {
+	param := r.URL.Query().Get("nutrients")
+	if param == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// keepNutrients drops the nutrient totals not named in allow, ignoring case.
+func keepNutrients(stats *db.RangeStats, allow []string) {
+	if allow == nil {
+		return
+	}
+	kept := []db.NutrientTotal{}
+	for _, n := range stats.Nutrients {
+		for _, name := range allow {
+			if strings.EqualFold(n.Name, name) {
+				kept = append(kept, n)
+				break
+			}
+		}
+	}
+	stats.Nutrients = kept
+}
+
+// statsPeriod reads either ?period= and ?date= or a ?from=&to= range of
+// days, in the caller's calendar. to defaults to today.
+func statsPeriod(r *http.Request, cal calendar) (db.Period, error) {
+	q := r.URL.Query()
+	today := time.Now().In(cal.loc)
+	from, to := q.Get("from"), q.Get("to")
+	if from == "" && to == "" {
+		date := today
+		if dateStr := q.Get("date"); dateStr != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", dateStr, cal.loc)
+			if err == nil {
+				date = parsed
+			}
+		}
+		return db.NewPeriod(q.Get("period"), date, cal.weekStart)
+	}
+
+	if q.Get("period") != "" {
+		return db.Period{}, errors.New("give either period or from/to, not both")
+	}
+	if from == "" {
+		return db.Period{}, errors.New("from is required with to")
+	}
+	start, err := time.ParseInLocation("2006-01-02", from, cal.loc)
+	if err != nil {
+		return db.Period{}, fmt.Errorf("invalid from: %s", from)
+	}
+	end := today
+	if to != "" {
+		end, err = time.ParseInLocation("2006-01-02", to, cal.loc)
+		if err != nil {
+			return db.Period{}, fmt.Errorf("invalid to: %s", to)
+		}
+	}
+	return db.RangePeriod(start, end)
+}
+
 // ### Logs
 // - GET /logs
 //     - Query Params: ?date=YYYY-MM-DD (Defaults to today)
-//     - Returns logs for the day
+//       or ?from=YYYY-MM-DD&to=YYYY-MM-DD (inclusive; either may be omitted)
+//     - Returns logs for the day or range as { items: [], next_cursor }, ordered by logged_at
+//     - Query Params: ?limit=&cursor=&sort={logged_at,created_at}
+//     - ?meal_tag= returns only that meal's logs
+//     - ?include=food embeds { name, brand, calories, protein, carbs, fat, nutrients }
+//       for the logged amount as "food" on each log
+//     - Days start at midnight in the profile time zone, or ?tz= (IANA name) if given
 // - POST /logs
 //     - Create log entry
-//     - Payload: { food_id, amount, meal_tag, logged_at (optional) }
+//     - Payload: { food_id, amount, unit (optional), meal_tag, logged_at (optional, defaults to now) }
+//     - unit defaults to the food's measurement_unit and must convert to it
+//     - food_id must be a food the caller can see (404 otherwise)
+//     - Recipes may give servings or grams (cooked weight) instead of amount
+//     - Or give portion_id and quantity (defaults to 1) to log a named portion
 // - DELETE /logs/{id}
 
-func RegisterLogsPaths(mux *http.ServeMux) {
-	mux.HandleFunc("GET /logs", getLogsHandler)
-	mux.HandleFunc("POST /logs", createLogEntryHandler)
-	mux.HandleFunc("DELETE /logs/{id}", deleteLogEntryHandler)
+func RegisterLogsPaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /logs", h.getLogsHandler)
+	mux.HandleFunc("POST /logs", h.createLogEntryHandler)
+	mux.HandleFunc("DELETE /logs/{id}", h.deleteLogEntryHandler)
 }
 
-func getLogsHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) getLogsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	logs, err := db.GetFoodLogEntries(userID, time.Now())
+	opts, err := pageOptions(r)
 	if err != nil {
-		http.Error(w, "Failed to get logs", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cal, err := h.userCalendar(r, userID)
+	if err != nil {
+		writeProfileError(w, err, "Failed to get logs")
+		return
+	}
+	filter, err := logFilter(r, cal.loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs, err := h.store.GetFoodLogEntries(r.Context(), userID, filter, opts)
+	if err != nil {
+		writeListError(w, err, "Failed to get logs")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(logs)
 }
 
+// logFilter reads the day or from/to range, meal_tag and include query
+// parameters of GET /logs. Dates are whole days in loc; to is inclusive.
+func logFilter(r *http.Request, loc *time.Location) (db.LogFilter, error) {
+	q := r.URL.Query()
+	date, from, to := q.Get("date"), q.Get("from"), q.Get("to")
+	if date != "" && (from != "" || to != "") {
+		return db.LogFilter{}, errors.New("give either date or from/to, not both")
+	}
+
+	filter := db.DayFilter(time.Now().In(loc))
+	if date != "" {
+		day, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return db.LogFilter{}, fmt.Errorf("invalid date: %s", date)
+		}
+		filter = db.DayFilter(day)
+	}
+	if from != "" || to != "" {
+		filter = db.LogFilter{}
+		if from != "" {
+			day, err := time.ParseInLocation("2006-01-02", from, loc)
+			if err != nil {
+				return db.LogFilter{}, fmt.Errorf("invalid from: %s", from)
+			}
+			filter.From = db.DayFilter(day).From
+		}
+		if to != "" {
+			day, err := time.ParseInLocation("2006-01-02", to, loc)
+			if err != nil {
+				return db.LogFilter{}, fmt.Errorf("invalid to: %s", to)
+			}
+			filter.To = db.DayFilter(day).To
+		}
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+			return db.LogFilter{}, errors.New("from must not be after to")
+		}
+	}
+
+	filter.MealTag = q.Get("meal_tag")
+	switch include := q.Get("include"); include {
+	case "":
+	case "food":
+		filter.IncludeFood = true
+	default:
+		return db.LogFilter{}, fmt.Errorf("invalid include: %s", include)
+	}
+	return filter, nil
+}
+
 type createLogEntryRequest struct {
 	FoodID   db.FoodID `json:"food_id"`
 	Amount   float64   `json:"amount"`
+	Unit     string    `json:"unit"`
 	MealTag  string    `json:"meal_tag"`
 	LoggedAt time.Time `json:"logged_at"`
+	// Servings or Grams log a recipe by serving count or cooked weight
+	// instead of Amount. They are stored as an amount in "serving" or "g".
+	Servings float64 `json:"servings"`
+	Grams    float64 `json:"grams"`
+	// PortionID and Quantity log a number of a food's named portions.
+	PortionID *db.FoodPortionID `json:"portion_id"`
+	Quantity  float64           `json:"quantity"`
 }
 
-func createLogEntryHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) createLogEntryHandler(w http.ResponseWriter, r *http.Request) {
 	var req createLogEntryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -290,16 +891,49 @@ func createLogEntryHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	entry, err := db.CreateFoodLogEntry(db.FoodLogEntry{UserID: userID, FoodID: req.FoodID, Amount: req.Amount, MealTag: req.MealTag, LoggedAt: req.LoggedAt})
+	entry := db.FoodLogEntry{UserID: userID, FoodID: req.FoodID, Amount: req.Amount, Unit: req.Unit, MealTag: req.MealTag, LoggedAt: req.LoggedAt}
+	given := 0
+	for _, set := range []bool{req.Amount != 0, req.Servings != 0, req.Grams != 0, req.PortionID != nil} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		http.Error(w, "Give only one of amount, servings, grams or portion_id", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case req.PortionID != nil:
+		entry.PortionID, entry.Quantity = req.PortionID, req.Quantity
+	case req.Servings != 0:
+		entry.Amount, entry.Unit = req.Servings, "serving"
+	case req.Grams != 0:
+		entry.Amount, entry.Unit = req.Grams, "g"
+	}
+	created, err := h.store.CreateFoodLogEntry(r.Context(), entry)
 	if err != nil {
-		http.Error(w, "Failed to create log entry", http.StatusInternalServerError)
+		writeLogEntryError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(entry)
+	json.NewEncoder(w).Encode(created)
+}
+
+// writeLogEntryError maps an error from creating a log entry to a response.
+// Foods the caller cannot see are reported as 404, like on /foods/{id}.
+func writeLogEntryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrFoodNotFound):
+		writeAccessError(w, err, "Failed to create log entry")
+	case errors.Is(err, db.ErrPortionNotFound), errors.Is(err, units.ErrIncompatible):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("failed to create log entry", "error", err)
+		http.Error(w, "Failed to create log entry", http.StatusInternalServerError)
+	}
 }
 
-func deleteLogEntryHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) deleteLogEntryHandler(w http.ResponseWriter, r *http.Request) {
 	logEntryIdString := r.PathValue("id")
 	logEntryId, err := uuid.Parse(logEntryIdString)
 	if err != nil {
@@ -311,7 +945,7 @@ func deleteLogEntryHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if err := db.DeleteFoodLogEntry(db.FoodLogEntryID(logEntryId), userID); err != nil {
+	if err := h.store.DeleteFoodLogEntry(r.Context(), db.FoodLogEntryID(logEntryId), userID); err != nil {
 		slog.Error("failed to delete log entry", "error", err, "id", logEntryId)
 		http.Error(w, "Failed to delete log entry", http.StatusInternalServerError)
 		return
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"azule.info/calorize/internal/db"
+	"github.com/google/uuid"
+)
+
+func TestUpdateFoodPreconditions(t *testing.T) {
+	mux, store := newTestServer(t)
+	owner := createUser(t, store, "owner")
+	id := createFood(t, store, owner, "Rice", false)
+	path := "/foods/" + uuid.UUID(id).String()
+	body := `{"name": "White Rice", "measurement_unit": "g", "measurement_amount": 100, "calories": 130}`
+	ifMatch := func(tag string) http.Header { return http.Header{"If-Match": {tag}} }
+
+	if got := send(mux, owner, "GET", path, "", nil).Header().Get("ETag"); got != `"1"` {
+		t.Errorf("Expected ETag \"1\", got %s", got)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   int
+		etag   string
+	}{
+		{"no base version", nil, body, http.StatusPreconditionRequired, ""},
+		{"invalid If-Match", ifMatch("latest"), body, http.StatusBadRequest, ""},
+		{"current version", ifMatch(`"1"`), body, http.StatusOK, `"2"`},
+		{"stale version", ifMatch(`"1"`), body, http.StatusConflict, ""},
+		{"unknown version", ifMatch(`"9"`), body, http.StatusConflict, ""},
+		{"base_version in body", nil, `{"name": "Brown Rice", "measurement_unit": "g", "measurement_amount": 100, "base_version": 2}`, http.StatusOK, `"3"`},
+		{"If-Match wins over body", ifMatch(`W/"2"`), `{"name": "Rice", "measurement_unit": "g", "measurement_amount": 100, "base_version": 3}`, http.StatusConflict, ""},
+	}
+	for _, tt := range tests {
+		w := send(mux, owner, "PUT", path, tt.body, tt.header)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+		if tt.etag != "" && w.Header().Get("ETag") != tt.etag {
+			t.Errorf("%s: expected ETag %s, got %s", tt.name, tt.etag, w.Header().Get("ETag"))
+		}
+	}
+
+	versions, err := store.GetFoodVersions(t.Context(), owner, db.FoodID(id))
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Name != "Brown Rice" {
+		t.Errorf("Expected 3 versions ending in Brown Rice, got %d", len(versions))
+	}
+}
+
+func TestFoodVersionAccess(t *testing.T) {
+	mux, store := newTestServer(t)
+	owner := createUser(t, store, "owner")
+	other := createUser(t, store, "other")
+	id := createFood(t, store, owner, "Rice", true)
+	path := "/foods/" + uuid.UUID(id).String()
+
+	if got := serve(mux, other, "POST", "/logs", `{"food_id": "`+uuid.UUID(id).String()+`", "amount": 100}`); got != http.StatusOK {
+		t.Fatalf("Expected logging the public food to succeed, got %d", got)
+	}
+	if _, err := store.UpdateFood(t.Context(), id, db.Food{CreatorID: owner, Name: "Secret Rice", Public: true, MeasurementUnit: "g", MeasurementAmount: 100}); err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	if got := serve(mux, owner, "POST", path+"/unpublish", ""); got != http.StatusOK {
+		t.Fatalf("Expected unpublishing to succeed, got %d", got)
+	}
+
+	// Only the version the other user logged is listed
+	w := send(mux, other, "GET", path+"/versions", "", nil)
+	var versions []db.Food
+	if err := json.NewDecoder(w.Body).Decode(&versions); err != nil {
+		t.Fatalf("failed to decode versions: %v", err)
+	}
+	if w.Code != http.StatusOK || len(versions) != 1 || versions[0].Version != 1 {
+		t.Errorf("Expected only version 1, got %d with %+v", w.Code, versions)
+	}
+	if got := serve(mux, owner, "GET", path+"/versions", ""); got != http.StatusOK {
+		t.Errorf("Expected the owner to list versions, got %d", got)
+	}
+
+	tests := []struct {
+		name string
+		user db.UserID
+		path string
+		want int
+	}{
+		{"other diffs logged version", other, path + "/diff?from=1&to=1", http.StatusOK},
+		{"other diffs to current", other, path + "/diff?from=1", http.StatusNotFound},
+		{"other diffs by default", other, path + "/diff", http.StatusNotFound},
+		{"owner diffs", owner, path + "/diff?from=1&to=2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := serve(mux, tt.user, "GET", tt.path, ""); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestUpdateFoodCascadeFailure(t *testing.T) {
+	mux, store := newTestServer(t)
+	owner := createUser(t, store, "owner")
+	flour, err := store.CreateFood(t.Context(), db.Food{CreatorID: owner, Name: "Flour", MeasurementUnit: "g", MeasurementAmount: 100, Calories: 360, Density: 0.5})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	if _, err := store.CreateFood(t.Context(), db.Food{CreatorID: owner, Name: "Bread", MeasurementUnit: "serving", MeasurementAmount: 1,
+		Ingredients: []db.RecipeItems{{IngredientID: flour.ID, Amount: 2, Unit: "cup"}}}); err != nil {
+		t.Fatalf("CreateFood (recipe) failed: %v", err)
+	}
+
+	// Without a density the pinned bread cannot move to the new flour, but
+	// the flour update itself is kept
+	body := `{"name": "Flour", "measurement_unit": "g", "measurement_amount": 100, "calories": 360}`
+	w := send(mux, owner, "PUT", "/foods/"+uuid.UUID(flour.ID).String()+"?cascade=true", body, http.Header{"If-Match": {`"1"`}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Version      int       `json:"version"`
+		Cascaded     []db.Food `json:"cascaded"`
+		CascadeError string    `json:"cascade_error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Version != 2 || len(resp.Cascaded) != 0 || resp.CascadeError == "" {
+		t.Errorf("Expected version 2 with a cascade error, got %+v", resp)
+	}
+	versions, err := store.GetFoodVersions(t.Context(), owner, flour.ID)
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	if len(versions) != 2 || !versions[0].IsCurrent || versions[0].Density != 0 {
+		t.Errorf("Expected the update to be saved, got %d versions", len(versions))
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"azule.info/calorize/internal/db"
+	"github.com/google/uuid"
+)
+
+// ### Goals
+// - GET /goals
+//     - Lists the caller's goals, the most recently effective first
+// - POST /goals
+//     - Payload: { effective_from, targets: [{ nutrient, unit, min, max }] }
+//     - effective_from is YYYY-MM-DD and defaults to today; a goal already taking
+//       effect that day is replaced
+//     - nutrient is calories, protein, carbs, fat or a micronutrient name
+//     - unit is kcal for calories, g or % (of calories) for macros, and required
+//       for micronutrients; each target needs a min, a max or both
+// - DELETE /goals/{id}
+//
+// Each day is judged against the goal in effect on it, so changing goals does
+// not rewrite history. GET /stats reports progress against them.
+
+func RegisterGoalsPaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /goals", h.getGoalsHandler)
+	mux.HandleFunc("POST /goals", h.createGoalHandler)
+	mux.HandleFunc("DELETE /goals/{id}", h.deleteGoalHandler)
+}
+
+// { effective_from, targets: [] }
+type goalRequest struct {
+	EffectiveFrom string          `json:"effective_from"`
+	Targets       []db.GoalTarget `json:"targets"`
+}
+
+func (h *handlers) getGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goals, err := h.store.GetGoals(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to list goals", "error", err)
+		http.Error(w, "Failed to get goals", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goals)
+}
+
+func (h *handlers) createGoalHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req goalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.EffectiveFrom == "" {
+		cal, err := h.userCalendar(r, userID)
+		if err != nil {
+			writeProfileError(w, err, "Failed to set goal")
+			return
+		}
+		req.EffectiveFrom = time.Now().In(cal.loc).Format("2006-01-02")
+	}
+	goal, err := h.store.SetGoal(r.Context(), db.Goal{UserID: userID, EffectiveFrom: req.EffectiveFrom, Targets: req.Targets})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidGoal) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Error("failed to set goal", "error", err)
+		http.Error(w, "Failed to set goal", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goal)
+}
+
+func (h *handlers) deleteGoalHandler(w http.ResponseWriter, r *http.Request) {
+	goalID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store.DeleteGoal(r.Context(), userID, db.GoalID(goalID)); err != nil {
+		slog.Error("failed to delete goal", "error", err, "id", goalID)
+		http.Error(w, "Failed to delete goal", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
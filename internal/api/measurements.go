@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"azule.info/calorize/internal/db"
+	"github.com/google/uuid"
+)
+
+// ### Measurements
+// - GET /measurements
+//     - Query Params: ?kind= and the range as for /stats (period and date, or
+//       from and to), defaulting to the last 30 days
+//     - Returns { measurements: [], trends: [{ kind, unit, days: [{ date, value,
+//       readings, trend }] }] }
+//     - Each trend day averages that day's readings in the unit of the latest
+//       one; trend is their exponentially weighted moving average, which
+//       smooths out day to day swings
+//     - Days start at midnight in the profile time zone, or ?tz= if given
+// - POST /measurements
+//     - Payload: { kind, value, unit, measured_at (optional, defaults to now) }
+//     - kind is weight, body_fat, waist, hips, chest, neck, arm or thigh
+//     - unit defaults to kg for weight, % for body_fat and cm for the rest, and
+//       must be a mass, % or length unit respectively
+// - PUT /measurements/{id}
+//     - Payload: Same as POST, but measured_at is required
+// - DELETE /measurements/{id}
+
+func RegisterMeasurementsPaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /measurements", h.getMeasurementsHandler)
+	mux.HandleFunc("POST /measurements", h.createMeasurementHandler)
+	mux.HandleFunc("PUT /measurements/{id}", h.updateMeasurementHandler)
+	mux.HandleFunc("DELETE /measurements/{id}", h.deleteMeasurementHandler)
+}
+
+// { kind, value, unit, measured_at }
+type measurementRequest struct {
+	Kind       string    `json:"kind"`
+	Value      float64   `json:"value"`
+	Unit       string    `json:"unit"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// { measurements: [], trends: [] }
+type measurementsResponse struct {
+	Measurements []db.Measurement      `json:"measurements"`
+	Trends       []db.MeasurementTrend `json:"trends"`
+}
+
+func (h *handlers) getMeasurementsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cal, err := h.userCalendar(r, userID)
+	if err != nil {
+		writeProfileError(w, err, "Failed to get measurements")
+		return
+	}
+	var period db.Period
+	q := r.URL.Query()
+	if q.Get("period") == "" && q.Get("from") == "" && q.Get("to") == "" {
+		period, err = db.NewPeriod("last_30_days", time.Now().In(cal.loc), cal.weekStart)
+	} else {
+		period, err = statsPeriod(r, cal)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kind := q.Get("kind")
+	measurements, err := h.store.GetMeasurements(r.Context(), userID, db.MeasurementFilter{Kind: kind, From: period.Start, To: period.End})
+	if err != nil {
+		slog.Error("failed to list measurements", "error", err)
+		http.Error(w, "Failed to get measurements", http.StatusInternalServerError)
+		return
+	}
+	trends, err := h.store.GetMeasurementTrends(r.Context(), userID, kind, period)
+	if err != nil {
+		slog.Error("failed to get measurement trends", "error", err)
+		http.Error(w, "Failed to get measurements", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(measurementsResponse{Measurements: measurements, Trends: trends})
+}
+
+func (h *handlers) createMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req measurementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	m, err := h.store.CreateMeasurement(r.Context(), db.Measurement{
+		UserID:     userID,
+		Kind:       req.Kind,
+		Value:      req.Value,
+		Unit:       req.Unit,
+		MeasuredAt: req.MeasuredAt,
+	})
+	if err != nil {
+		writeMeasurementError(w, err, "Failed to create measurement")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+func (h *handlers) updateMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid measurement ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req measurementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	m, err := h.store.UpdateMeasurement(r.Context(), db.Measurement{
+		ID:         db.MeasurementID(id),
+		UserID:     userID,
+		Kind:       req.Kind,
+		Value:      req.Value,
+		Unit:       req.Unit,
+		MeasuredAt: req.MeasuredAt,
+	})
+	if err != nil {
+		writeMeasurementError(w, err, "Failed to update measurement")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+func (h *handlers) deleteMeasurementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid measurement ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store.DeleteMeasurement(r.Context(), userID, db.MeasurementID(id)); err != nil {
+		slog.Error("failed to delete measurement", "error", err, "id", id)
+		http.Error(w, "Failed to delete measurement", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeMeasurementError maps an error from writing a measurement to a
+// response.
+func writeMeasurementError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, db.ErrMeasurementNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrInvalidMeasurement):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("measurement write failed", "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
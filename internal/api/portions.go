@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"azule.info/calorize/internal/db"
+	"azule.info/calorize/internal/units"
+	"github.com/google/uuid"
+)
+
+// ### Portions
+// - GET /foods/{id}/portions
+//     - Lists the named portions of a food version
+// - POST /foods/{id}/portions
+//     - Payload: { name, amount, unit }
+//     - unit defaults to the food's measurement_unit and must convert to it
+// - PUT /foods/{id}/portions/{portionID}
+//     - Payload: Same as POST
+// - DELETE /foods/{id}/portions/{portionID}
+//
+// Portions belong to a food version and are copied when the food is updated.
+// Only the food's creator may change them, and only on the current version;
+// older versions answer 409.
+
+// { name, amount, unit }
+type portionRequest struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+func (h *handlers) getFoodPortionsHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.ViewFood) {
+		return
+	}
+	portions, err := h.store.GetFoodPortions(r.Context(), db.FoodID(foodID))
+	if err != nil {
+		slog.Error("failed to list portions", "error", err, "id", foodID)
+		http.Error(w, "Failed to get portions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portions)
+}
+
+func (h *handlers) createFoodPortionHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	var req portionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.EditFood) {
+		return
+	}
+	portion, err := h.store.CreateFoodPortion(r.Context(), db.FoodPortion{
+		FoodID: db.FoodID(foodID),
+		Name:   req.Name,
+		Amount: req.Amount,
+		Unit:   req.Unit,
+	})
+	if err != nil {
+		writePortionError(w, err, "Failed to create portion")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portion)
+}
+
+func (h *handlers) updateFoodPortionHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	portionID, err := uuid.Parse(r.PathValue("portionID"))
+	if err != nil {
+		http.Error(w, "Invalid portion ID", http.StatusBadRequest)
+		return
+	}
+	var req portionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.EditFood) {
+		return
+	}
+	portion, err := h.store.UpdateFoodPortion(r.Context(), db.FoodPortion{
+		ID:     db.FoodPortionID(portionID),
+		FoodID: db.FoodID(foodID),
+		Name:   req.Name,
+		Amount: req.Amount,
+		Unit:   req.Unit,
+	})
+	if err != nil {
+		writePortionError(w, err, "Failed to update portion")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portion)
+}
+
+func (h *handlers) deleteFoodPortionHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	portionID, err := uuid.Parse(r.PathValue("portionID"))
+	if err != nil {
+		http.Error(w, "Invalid portion ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.EditFood) {
+		return
+	}
+	if err := h.store.DeleteFoodPortion(r.Context(), db.FoodID(foodID), db.FoodPortionID(portionID)); err != nil {
+		writePortionError(w, err, "Failed to delete portion")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writePortionError maps an error from writing or deleting a portion to a
+// response.
+func writePortionError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, db.ErrFoodNotFound), errors.Is(err, db.ErrPortionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrInvalidPortion), errors.Is(err, units.ErrIncompatible):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, db.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.Error("portion write failed", "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
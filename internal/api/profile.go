@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"azule.info/calorize/internal/db"
+)
+
+// ### Profile
+// - GET /profile
+//     - Returns the caller's user record, including timezone and week_start
+// - PUT /profile
+//     - Payload: { timezone, week_start }
+//     - timezone is an IANA name such as "America/Los_Angeles"; empty means UTC
+//     - week_start is a day name such as "sunday"; empty means Monday
+//
+// Days in logs and stats start at midnight in the profile's time zone. Those
+// endpoints also accept ?tz= to use another zone for a single request.
+
+func RegisterProfilePaths(mux *http.ServeMux, store db.Store) {
+	h := &handlers{store: store}
+	mux.HandleFunc("GET /profile", h.getProfileHandler)
+	mux.HandleFunc("PUT /profile", h.updateProfileHandler)
+}
+
+// { timezone, week_start }. Omitted fields are left unchanged.
+type profileRequest struct {
+	TimeZone  *string `json:"timezone"`
+	WeekStart *string `json:"week_start"`
+}
+
+func (h *handlers) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := h.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to get profile", "error", err)
+		http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *handlers) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req profileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	user, err := h.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		slog.Error("failed to get profile", "error", err)
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if req.TimeZone != nil {
+		user.TimeZone = *req.TimeZone
+	}
+	if req.WeekStart != nil {
+		user.WeekStart = strings.ToLower(*req.WeekStart)
+	}
+	updated, err := h.store.UpdateUser(r.Context(), *user)
+	if err != nil {
+		writeProfileError(w, err, "Failed to update profile")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// calendar is how a request divides time into days and weeks.
+type calendar struct {
+	loc       *time.Location
+	weekStart time.Weekday
+}
+
+// userCalendar returns the caller's profile calendar. The tz query parameter,
+// if given, replaces the profile time zone for this request.
+func (h *handlers) userCalendar(r *http.Request, userID db.UserID) (calendar, error) {
+	cal := calendar{loc: time.UTC, weekStart: time.Monday}
+	user, err := h.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return calendar{}, err
+	}
+	if user != nil {
+		cal.loc, cal.weekStart = user.Location(), user.FirstWeekday()
+	}
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := db.LoadTimeZone(tz)
+		if err != nil {
+			return calendar{}, err
+		}
+		cal.loc = loc
+	}
+	return cal, nil
+}
+
+// writeProfileError maps an error from reading or updating profile settings
+// to a response.
+func writeProfileError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, db.ErrInvalidTimeZone) || errors.Is(err, db.ErrInvalidWeekStart) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Error(msg, "error", err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"azule.info/calorize/internal/db"
+	"github.com/google/uuid"
+)
+
+// ### Versions
+// - GET /foods/{id}/versions
+//     - Lists every live version of the food's family the caller can see, newest first
+// - GET /foods/{id}/diff?from=1&to=3
+//     - Returns { from, to, fields: [{ field, from, to }], nutrients: [{ name, unit, from, to }],
+//       ingredients: [{ family_id, name, from, to }] } as the versions were stored
+//     - Versions may be written as 3 or v3; to defaults to the current version and from
+//       to the one before to
+//     - from is null for added nutrients and ingredients, and to for removed ones
+// - POST /foods/{id}/restore?version=n
+//     - Copies version n, with its nutrients, ingredients and portions, into a new
+//       current version; only the creator may restore
+//
+// An unknown version is reported as 404, as are versions the caller cannot
+// see: once a food is unpublished, other users only see the versions they
+// logged.
+
+// versionParam reads the version number in query parameter name, written as
+// 3 or v3. It returns 0 if the parameter is missing.
+func versionParam(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(v), "v"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s version: %s", name, v)
+	}
+	return n, nil
+}
+
+// writeVersionError maps an error from reading or restoring a food version to
+// a response.
+func writeVersionError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, db.ErrVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeFoodError(w, err, msg)
+}
+
+func (h *handlers) getFoodVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.ViewFood) {
+		return
+	}
+	versions, err := h.store.GetFoodVersions(r.Context(), userID, db.FoodID(foodID))
+	if err != nil {
+		slog.Error("failed to list versions", "error", err, "id", foodID)
+		http.Error(w, "Failed to get versions", http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []db.Food{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+func (h *handlers) diffFoodVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	from, err := versionParam(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := versionParam(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.authorizeFood(w, r, userID, db.FoodID(foodID), db.ViewFood) {
+		return
+	}
+	diff, err := h.store.DiffFoodVersions(r.Context(), userID, db.FoodID(foodID), from, to)
+	if err != nil {
+		writeVersionError(w, err, "Failed to compare versions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func (h *handlers) restoreFoodVersionHandler(w http.ResponseWriter, r *http.Request) {
+	foodID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid food ID", http.StatusBadRequest)
+		return
+	}
+	version, err := versionParam(r, "version")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if version == 0 {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+	userID, err := getUserID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	food, err := h.store.RestoreFoodVersion(r.Context(), userID, db.FoodID(foodID), version)
+	if err != nil {
+		writeVersionError(w, err, "Failed to restore version")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(food)
+}
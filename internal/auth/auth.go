@@ -24,7 +24,12 @@ var (
 	WebAuthn *webauthn.WebAuthn
 )
 
-func RegisterAuthPaths(mux *http.ServeMux) {
+// handlers carries the dependencies shared by the auth handlers.
+type handlers struct {
+	store db.Store
+}
+
+func RegisterAuthPaths(mux *http.ServeMux, store db.Store) {
 	var err error
 
 	rpDisplayName := os.Getenv("WEBAUTHN_RP_DISPLAY_NAME")
@@ -53,16 +58,17 @@ func RegisterAuthPaths(mux *http.ServeMux) {
 		panic(fmt.Errorf("failed to create WebAuthn from config: %w", err))
 	}
 
-	mux.HandleFunc("POST /auth/register/begin", registerBeginHandler)
-	mux.HandleFunc("POST /auth/register/finish", registerFinishHandler)
-	mux.HandleFunc("POST /auth/login/begin", loginBeginHandler)
-	mux.HandleFunc("POST /auth/login/finish", loginFinishHandler)
+	h := &handlers{store: store}
+	mux.HandleFunc("POST /auth/register/begin", h.registerBeginHandler)
+	mux.HandleFunc("POST /auth/register/finish", h.registerFinishHandler)
+	mux.HandleFunc("POST /auth/login/begin", h.loginBeginHandler)
+	mux.HandleFunc("POST /auth/login/finish", h.loginFinishHandler)
 
 	mux.HandleFunc("POST /auth/logout", func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie(AppSessionCookieName)
 		if err == nil && cookie.Value != "" {
 			// Best effort delete from DB
-			_ = db.DeleteSession(cookie.Value)
+			_ = store.DeleteSession(r.Context(), cookie.Value)
 		}
 
 		// Clear the session cookie
@@ -120,7 +126,7 @@ func clearSession(w http.ResponseWriter) {
 
 // Handlers
 
-func registerBeginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) registerBeginHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
 	if username == "" {
 		// Try form body
@@ -134,7 +140,7 @@ func registerBeginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user exists or create a temporary user representation
-	user, err := db.GetUser(username)
+	user, err := h.store.GetUser(r.Context(), username)
 	if err != nil {
 		http.Error(w, "database error", http.StatusInternalServerError)
 		return
@@ -146,14 +152,14 @@ func registerBeginHandler(w http.ResponseWriter, r *http.Request) {
 			Name:      username,
 			CreatedAt: time.Now(),
 		}
-		user, err = db.CreateUser(newUser)
+		user, err = h.store.CreateUser(r.Context(), newUser)
 		if err != nil {
 			http.Error(w, "failed to create user", http.StatusInternalServerError)
 			return
 		}
 	}
 
-	wUser := WebAuthnUser{User: user}
+	wUser := WebAuthnUser{User: user, store: h.store, ctx: r.Context()}
 
 	options, sessionData, err := WebAuthn.BeginRegistration(&wUser)
 	if err != nil {
@@ -171,7 +177,7 @@ func registerBeginHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(options)
 }
 
-func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 	sessionData, err := loadSession(r)
 	if err != nil {
 		http.Error(w, "session missing", http.StatusBadRequest)
@@ -188,7 +194,7 @@ func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Reconstruct user
 	username := r.URL.Query().Get("username")
-	user, err := db.GetUser(username)
+	user, err := h.store.GetUser(r.Context(), username)
 	if err != nil || user == nil {
 		// if nil, maybe we just created it and need to find it?
 		// If we created it, `GetUser` should find it.
@@ -199,7 +205,7 @@ func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	wUser := WebAuthnUser{User: user}
+	wUser := WebAuthnUser{User: user, store: h.store, ctx: r.Context()}
 
 	credential, err := WebAuthn.FinishRegistration(&wUser, *sessionData, r)
 	if err != nil {
@@ -208,7 +214,7 @@ func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save credential
-	if err := db.AddUserCredential(*user, db.UserCredential{
+	if err := h.store.AddUserCredential(r.Context(), *user, db.UserCredential{
 		ID:              db.UserCredentialID(credential.ID),
 		Name:            "Passkey", // Default name
 		PublicKey:       credential.PublicKey,
@@ -226,7 +232,7 @@ func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Auto-login? Create session.
-	session, err := db.CreateSession(user.ID)
+	session, err := h.store.CreateSession(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		return
@@ -259,16 +265,16 @@ func registerFinishHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func loginBeginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) loginBeginHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
 	// Retrieve user by name
-	user, err := db.GetUser(username)
+	user, err := h.store.GetUser(r.Context(), username)
 	if err != nil {
 		http.Error(w, "user not found", http.StatusBadRequest)
 		return
 	}
 
-	wUser := WebAuthnUser{User: user}
+	wUser := WebAuthnUser{User: user, store: h.store, ctx: r.Context()}
 
 	options, sessionData, err := WebAuthn.BeginLogin(&wUser)
 	if err != nil {
@@ -281,7 +287,7 @@ func loginBeginHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(options)
 }
 
-func loginFinishHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) loginFinishHandler(w http.ResponseWriter, r *http.Request) {
 	sessionData, err := loadSession(r)
 	if err != nil {
 		http.Error(w, "session missing", http.StatusBadRequest)
@@ -295,13 +301,13 @@ func loginFinishHandler(w http.ResponseWriter, r *http.Request) {
 	// I really need `GetUserByID`.
 	// I will fetch by username from query as workaround until I add GetUserByID.
 	username := r.URL.Query().Get("username")
-	user, err := db.GetUser(username)
+	user, err := h.store.GetUser(r.Context(), username)
 	if err != nil || user == nil {
 		http.Error(w, "user not found", http.StatusBadRequest)
 		return
 	}
 
-	wUser := WebAuthnUser{User: user}
+	wUser := WebAuthnUser{User: user, store: h.store, ctx: r.Context()}
 
 	credential, err := WebAuthn.FinishLogin(&wUser, *sessionData, r)
 	if err != nil {
@@ -313,11 +319,11 @@ func loginFinishHandler(w http.ResponseWriter, r *http.Request) {
 	// We need `UpdateUserCredential`? Or just ignore for now?
 	// Best practice: update sign count.
 	// I'll skip for now or add TODO.
-	db.SetCredentialLastUsed(*user, db.UserCredential{
+	h.store.SetCredentialLastUsed(r.Context(), *user, db.UserCredential{
 		ID: db.UserCredentialID(credential.ID),
 	})
 
-	session, err := db.CreateSession(user.ID)
+	session, err := h.store.CreateSession(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		return
@@ -19,8 +19,8 @@ func init() {
 		// Default dev key for local development convenience - DO NOT USE IN PRODUCTION
 		// This ensures existing dev workflows don't break immediately
 		slog.Warn("PASETO_SECRET_KEY not set - using insecure dev key")
-		// Hardcoded "random" hex string for dev
-		keyHex = "MANATEES ARE GREAT__AND You know it or YOU ARE A LIAR!!!!!!!!!!!"
+		// Hardcoded "random" hex string for dev ("MANATEES ARE GREAT__AND You know")
+		keyHex = "4d414e4154454553204152452047524541545f5f414e4420596f75206b6e6f77"
 	}
 
 	var err error
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+
 	"azule.info/calorize/internal/db"
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
@@ -9,6 +11,10 @@ import (
 
 type WebAuthnUser struct {
 	*db.User
+	store db.Store
+	// ctx is the request context; the webauthn.User interface offers no
+	// other way to pass it through to the credential lookup.
+	ctx context.Context
 }
 
 func (u *WebAuthnUser) WebAuthnID() []byte {
@@ -32,7 +38,7 @@ func (u *WebAuthnUser) WebAuthnIcon() string {
 }
 
 func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
-	creds, err := db.GetUserCredentials(*u.User)
+	creds, err := u.store.GetUserCredentials(u.ctx, *u.User)
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testFoodAccess(t *testing.T, s Store) {
+	owner := createTestUser(t, s)
+	other := createTestUser(t, s)
+	private := createSearchFood(t, s, owner, "Private Food", "", false)
+	public := createSearchFood(t, s, owner, "Public Food", "", true)
+	missing := FoodID(uuid.New())
+
+	// 1. Viewing: owners see everything, others only public foods
+	views := []struct {
+		user *User
+		id   FoodID
+		want error
+	}{
+		{owner, private.ID, nil},
+		{owner, public.ID, nil},
+		{other, public.ID, nil},
+		{other, private.ID, ErrFoodNotFound},
+		{other, missing, ErrFoodNotFound},
+	}
+	for _, v := range views {
+		if err := s.AuthorizeFood(t.Context(), v.user.ID, v.id, ViewFood); !errors.Is(err, v.want) {
+			t.Errorf("ViewFood(%v): expected %v, got %v", v.id, v.want, err)
+		}
+	}
+
+	// 2. Editing: other users' private foods are hidden, public ones forbidden
+	edits := []struct {
+		id   FoodID
+		want error
+	}{
+		{private.ID, ErrFoodNotFound},
+		{public.ID, ErrForbidden},
+		{missing, ErrFoodNotFound},
+	}
+	for _, e := range edits {
+		if err := s.AuthorizeFood(t.Context(), other.ID, e.id, EditFood); !errors.Is(err, e.want) {
+			t.Errorf("EditFood(%v): expected %v, got %v", e.id, e.want, err)
+		}
+		if _, err := s.UpdateFood(t.Context(), e.id, Food{CreatorID: other.ID, Name: "Hijacked", MeasurementUnit: "g", MeasurementAmount: 100}); !errors.Is(err, e.want) {
+			t.Errorf("UpdateFood(%v): expected %v, got %v", e.id, e.want, err)
+		}
+		if err := s.DeleteFood(t.Context(), other.ID, e.id); !errors.Is(err, e.want) {
+			t.Errorf("DeleteFood(%v): expected %v, got %v", e.id, e.want, err)
+		}
+	}
+	// An update without a creator is made by nobody, not by the owner
+	for _, e := range edits[:2] {
+		if _, err := s.UpdateFood(t.Context(), e.id, Food{Name: "Hijacked", MeasurementUnit: "g", MeasurementAmount: 100}); !errors.Is(err, e.want) {
+			t.Errorf("UpdateFood(%v) without a creator: expected %v, got %v", e.id, e.want, err)
+		}
+	}
+	if err := s.AuthorizeFood(t.Context(), owner.ID, public.ID, EditFood); err != nil {
+		t.Errorf("Expected the owner to edit, got %v", err)
+	}
+
+	// 3. Other users' private foods cannot be logged, with or without a
+	// unit, so logging cannot be used to see them
+	for _, unit := range []string{"", "g"} {
+		entry := FoodLogEntry{UserID: other.ID, FoodID: private.ID, Amount: 100, Unit: unit, LoggedAt: time.Now()}
+		if _, err := s.CreateFoodLogEntry(t.Context(), entry); !errors.Is(err, ErrFoodNotFound) {
+			t.Errorf("CreateFoodLogEntry(unit %q): expected ErrFoodNotFound, got %v", unit, err)
+		}
+	}
+	logged := createTestLogEntry(t, s, other, public, 100, time.Now())
+	logged.FoodID = private.ID
+	if _, err := s.UpdateFoodLogEntry(t.Context(), *logged); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("UpdateFoodLogEntry: expected ErrFoodNotFound, got %v", err)
+	}
+	if err := s.AuthorizeFood(t.Context(), other.ID, private.ID, ViewFood); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected the private food to stay hidden, got %v", err)
+	}
+
+	// 4. Other users' private foods cannot be used as ingredients, but a
+	// recipe keeps ingredients that are unpublished after it used them
+	recipe := func(id FoodID) Food {
+		return Food{CreatorID: other.ID, Name: "Mix", MeasurementUnit: "g", MeasurementAmount: 100, Ingredients: []RecipeItems{{IngredientID: id, Amount: 100}}}
+	}
+	if _, err := s.CreateFood(t.Context(), recipe(private.ID)); !errors.Is(err, ErrIngredientNotFound) {
+		t.Errorf("Expected ErrIngredientNotFound for a private ingredient, got %v", err)
+	}
+	shared := createSearchFood(t, s, owner, "Shared Food", "", true)
+	mix, err := s.CreateFood(t.Context(), recipe(shared.ID))
+	if err != nil {
+		t.Fatalf("CreateFood (public ingredient) failed: %v", err)
+	}
+	if _, err := s.SetFoodPublic(t.Context(), owner.ID, shared.ID, false); err != nil {
+		t.Fatalf("SetFoodPublic failed: %v", err)
+	}
+	if _, err := s.UpdateFood(t.Context(), mix.ID, recipe(shared.ID)); err != nil {
+		t.Errorf("Expected the recipe to keep its unpublished ingredient, got %v", err)
+	}
+	if _, err := s.CreateFood(t.Context(), recipe(shared.ID)); !errors.Is(err, ErrIngredientNotFound) {
+		t.Errorf("Expected ErrIngredientNotFound for a new recipe, got %v", err)
+	}
+
+	// 5. Nothing changed hands
+	current, err := s.GetFood(t.Context(), public.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if !current.IsCurrent || current.DeletedAt != nil || current.Name != "Public Food" {
+		t.Errorf("Expected the public food untouched, got %+v", current)
+	}
+
+	// 6. The owner can update and delete; deleted foods stay viewable but
+	// can no longer be edited
+	updated, err := s.UpdateFood(t.Context(), public.ID, Food{CreatorID: owner.ID, Name: "Public Food v2", Public: true, MeasurementUnit: "g", MeasurementAmount: 100})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	if updated.CreatorID != owner.ID {
+		t.Errorf("Expected creator to stay %v, got %v", owner.ID, updated.CreatorID)
+	}
+	if err := s.DeleteFood(t.Context(), owner.ID, updated.ID); err != nil {
+		t.Fatalf("DeleteFood failed: %v", err)
+	}
+	if err := s.AuthorizeFood(t.Context(), other.ID, updated.ID, ViewFood); err != nil {
+		t.Errorf("Expected deleted public food to stay viewable, got %v", err)
+	}
+	if err := s.DeleteFood(t.Context(), owner.ID, updated.ID); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound deleting twice, got %v", err)
+	}
+}
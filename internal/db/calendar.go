@@ -0,0 +1,169 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the zone database so time zones resolve on hosts without one.
+	_ "time/tzdata"
+)
+
+var (
+	// ErrInvalidTimeZone is returned for a time zone that is not a known
+	// IANA name.
+	ErrInvalidTimeZone = errors.New("invalid time zone")
+	// ErrInvalidWeekStart is returned for a week start that is not a day
+	// name.
+	ErrInvalidWeekStart = errors.New("invalid week start")
+	// ErrInvalidPeriod is returned for an unknown period name or an empty
+	// range.
+	ErrInvalidPeriod = errors.New("invalid period")
+)
+
+// LoadTimeZone resolves an IANA time zone name. The empty name is UTC, the
+// default for users who have not chosen a zone.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimeZone, name)
+	}
+	return loc, nil
+}
+
+// Location returns the user's time zone, falling back to UTC if the stored
+// name no longer resolves.
+func (u *User) Location() *time.Location {
+	loc, err := LoadTimeZone(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseWeekday resolves a lowercase English day name such as "sunday". The
+// empty name is Monday, the default week start.
+func ParseWeekday(name string) (time.Weekday, error) {
+	if name == "" {
+		return time.Monday, nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.ToLower(d.String()) == name {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrInvalidWeekStart, name)
+}
+
+// FirstWeekday returns the day the user's weeks start on, falling back to
+// Monday if the stored name is not a day.
+func (u *User) FirstWeekday() time.Weekday {
+	d, err := ParseWeekday(u.WeekStart)
+	if err != nil {
+		return time.Monday
+	}
+	return d
+}
+
+// checkCalendar validates the user's time zone and week start.
+func (u *User) checkCalendar() error {
+	if _, err := LoadTimeZone(u.TimeZone); err != nil {
+		return err
+	}
+	_, err := ParseWeekday(u.WeekStart)
+	return err
+}
+
+// Period is a span of whole days, from the local midnight starting Start to
+// the one ending the last day at End.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// startOfDay returns local midnight on t's day in t's location.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// NewPeriod returns the named period containing date, in date's location.
+// Calendar periods are "day", "week" (starting on weekStart), "month" and
+// "year"; rolling windows "last_7_days", "last_30_days" or any
+// "last_N_days" end with date's day.
+func NewPeriod(name string, date time.Time, weekStart time.Weekday) (Period, error) {
+	day := startOfDay(date)
+	y, m, _ := day.Date()
+
+	switch name {
+	case "day":
+		return Period{Start: day, End: day.AddDate(0, 0, 1)}, nil
+	case "week":
+		offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+		start := day.AddDate(0, 0, -offset)
+		return Period{Start: start, End: start.AddDate(0, 0, 7)}, nil
+	case "month":
+		start := time.Date(y, m, 1, 0, 0, 0, 0, day.Location())
+		return Period{Start: start, End: start.AddDate(0, 1, 0)}, nil
+	case "year":
+		start := time.Date(y, time.January, 1, 0, 0, 0, 0, day.Location())
+		return Period{Start: start, End: start.AddDate(1, 0, 0)}, nil
+	}
+
+	if n, ok := strings.CutPrefix(name, "last_"); ok {
+		if n, ok := strings.CutSuffix(n, "_days"); ok {
+			days, err := strconv.Atoi(n)
+			if err == nil && days > 0 && days <= 366 {
+				end := day.AddDate(0, 0, 1)
+				return Period{Start: end.AddDate(0, 0, -days), End: end}, nil
+			}
+		}
+	}
+	return Period{}, fmt.Errorf("%w: %s", ErrInvalidPeriod, name)
+}
+
+// RangePeriod returns the days from from through to inclusive, each taken in
+// its own location.
+func RangePeriod(from, to time.Time) (Period, error) {
+	p := Period{Start: startOfDay(from), End: startOfDay(to).AddDate(0, 0, 1)}
+	if !p.Start.Before(p.End) {
+		return Period{}, fmt.Errorf("%w: %s is after %s", ErrInvalidPeriod, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	return p, nil
+}
+
+// Buckets splits p into consecutive calendar "day", "week" (starting on
+// weekStart), "month" or "year" buckets. The first and last buckets are
+// clipped to p, so together they cover exactly p.
+func (p Period) Buckets(bucket string, weekStart time.Weekday) ([]Period, error) {
+	switch bucket {
+	case "day", "week", "month", "year":
+	default:
+		return nil, fmt.Errorf("%w: bucket %s", ErrInvalidPeriod, bucket)
+	}
+
+	var buckets []Period
+	for cur := p.Start; cur.Before(p.End); {
+		if len(buckets) == MaxSeriesBuckets {
+			return nil, fmt.Errorf("%w: more than %d buckets", ErrInvalidPeriod, MaxSeriesBuckets)
+		}
+		b, err := NewPeriod(bucket, cur, weekStart)
+		if err != nil {
+			return nil, err
+		}
+		if b.Start.Before(p.Start) {
+			b.Start = p.Start
+		}
+		if b.End.After(p.End) {
+			b.End = p.End
+		}
+		buckets = append(buckets, b)
+		cur = b.End
+	}
+	return buckets, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// conformanceTests is the behaviour every Store implementation must share.
+// Each test runs once per available backend against a fresh, empty database.
+var conformanceTests = []struct {
+	name string
+	fn   func(t *testing.T, s Store)
+}{
+	{"FoodLifecycle", testFoodLifecycle},
+	{"RecipeLifecycle", testRecipeLifecycle},
+	{"RecipeNutrition", testRecipeNutrition},
+	{"RecipeCycles", testRecipeCycles},
+	{"RecipeFollowLatest", testRecipeFollowLatest},
+	{"RecipeYield", testRecipeYield},
+	{"UnitConversion", testUnitConversion},
+	{"FoodPortions", testFoodPortions},
+	{"FoodAccess", testFoodAccess},
+	{"FoodFork", testFoodFork},
+	{"PublishFoods", testPublishFoods},
+	{"FoodVersions", testFoodVersions},
+	{"FoodVersionConflict", testFoodVersionConflict},
+	{"SearchFoods", testSearchFoods},
+	{"Pagination", testPagination},
+	{"GetStats", testGetStats},
+	{"StatsPeriods", testStatsPeriods},
+	{"StatsSeries", testStatsSeries},
+	{"StatsNutrients", testStatsNutrients},
+	{"StatsMeals", testStatsMeals},
+	{"Goals", testGoals},
+	{"Measurements", testMeasurements},
+	{"EnergyEstimate", testEnergyEstimate},
+	{"FoodLogEntries", testFoodLogEntries},
+	{"FoodLogFilters", testFoodLogFilters},
+	{"UserLifecycle", testUserLifecycle},
+	{"SessionLifecycle", testSessionLifecycle},
+	{"CancelledContext", testCancelledContext},
+}
+
+// testBackends maps a backend name to a function opening a fresh store.
+// PostgreSQL only runs when CALORIZE_TEST_POSTGRES_DSN points at a server,
+// as it does in CI.
+func testBackends() map[string]func(t *testing.T) Store {
+	backends := map[string]func(t *testing.T) Store{
+		"sqlite": newTestStore,
+	}
+	if dsn := os.Getenv("CALORIZE_TEST_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) Store { return newPostgresTestStore(t, dsn) }
+	}
+	return backends
+}
+
+func TestConformance(t *testing.T) {
+	for backend, open := range testBackends() {
+		t.Run(backend, func(t *testing.T) {
+			for _, tc := range conformanceTests {
+				t.Run(tc.name, func(t *testing.T) {
+					tc.fn(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+// newTestStore opens a SQLite database in a per-test temporary directory.
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	s, err := Open(t.Context(), Config{DSN: "file:" + filepath.Join(t.TempDir(), "test.db") + "?_fk=1&_journal=WAL&_busy_timeout=5000"})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newPostgresTestStore isolates each test in its own schema, dropped on cleanup.
+func newPostgresTestStore(t *testing.T, dsn string) Store {
+	t.Helper()
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+	schema := "calorize_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid postgres dsn: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	s, err := Open(t.Context(), Config{Driver: "postgres", DSN: u.String()})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestQueryTimeout(t *testing.T) {
+	s, err := Open(t.Context(), Config{
+		DSN:          "file:" + filepath.Join(t.TempDir(), "test.db") + "?_fk=1&_journal=WAL&_busy_timeout=5000",
+		QueryTimeout: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.GetUser(t.Context(), "anyone"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
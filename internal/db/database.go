@@ -1,39 +1,151 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"os"
+	"strings"
+	"time"
 
-	_ "github.com/glebarez/go-sqlite"
 	"github.com/pressly/goose/v3"
 )
 
-//go:embed migrations/*.sql
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
 var embedMigrations embed.FS
-var db *sql.DB
 
-func init() {
-	goose.SetBaseFS(embedMigrations)
+// DefaultDSN is used when no DSN is configured: a SQLite database in the
+// working directory. Earlier versions always used test.db there instead;
+// see defaultDSN.
+const DefaultDSN = "file:calorize.db?_fk=1&_journal=WAL&_busy_timeout=5000"
 
-	var err error
+// legacyDSN is the database earlier versions opened unconditionally.
+const legacyDSN = "file:./test.db?_fk=1&_journal=WAL&_busy_timeout=5000"
 
-	slog.Info("opening database", "path", "./test.db?_fk=1&_journal=WAL&_busy_timeout=5000")
-	db, err = sql.Open("sqlite", "file:./test.db?_fk=1&_journal=WAL&_busy_timeout=5000")
+// Config controls how the database is opened.
+type Config struct {
+	// Driver selects the backend: "sqlite" or "postgres". When empty it is
+	// inferred from the DSN, with postgres:// and postgresql:// URLs
+	// selecting PostgreSQL and everything else SQLite.
+	Driver string
+	// DSN is passed straight to the driver,
+	// e.g. "file:./calorize.db?_fk=1&_journal=WAL&_busy_timeout=5000"
+	// or "postgres://calorize@localhost/calorize".
+	DSN string
+	// QueryTimeout bounds every store call. Zero means calls are limited only
+	// by the caller's context.
+	QueryTimeout time.Duration
+	// MaxRecipeDepth limits how deeply recipes may nest inside each other.
+	// Zero means DefaultMaxRecipeDepth.
+	MaxRecipeDepth int
+}
+
+// sqlStore implements Store on top of database/sql. The SQL is shared between
+// backends; the dialect supplies the driver, migrations and placeholder style.
+type sqlStore struct {
+	db             *sql.DB
+	dialect        dialect
+	queryTimeout   time.Duration
+	maxRecipeDepth int
+}
+
+// Open opens the database described by cfg and runs any pending migrations.
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	dsn := cfg.DSN
+	if dsn == "" {
+		dsn = defaultDSN()
+	}
+
+	driver := cfg.Driver
+	if driver == "" {
+		driver = driverForDSN(dsn)
+	}
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+
+	slog.Info("opening database", "driver", driver)
+	conn, err := sql.Open(d.driver, dsn)
 	if err != nil {
-		slog.Error("failed to open database", "error", err)
-		panic(err)
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	if err := migrate(ctx, conn, d); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	maxDepth := cfg.MaxRecipeDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxRecipeDepth
+	}
+
+	slog.Info("database initialized", "driver", driver)
+	return &sqlStore{
+		db:             conn,
+		dialect:        d,
+		queryTimeout:   cfg.QueryTimeout,
+		maxRecipeDepth: maxDepth,
+	}, nil
+}
+
+// defaultDSN returns DefaultDSN, unless only the test.db of an earlier
+// version exists in the working directory, in which case that is used so
+// existing installs keep their data.
+func defaultDSN() string {
+	if _, err := os.Stat("calorize.db"); !errors.Is(err, fs.ErrNotExist) {
+		return DefaultDSN
+	}
+	if _, err := os.Stat("test.db"); err != nil {
+		return DefaultDSN
+	}
+	slog.Warn("using test.db from an earlier version; rename it to calorize.db to silence this warning")
+	return legacyDSN
+}
+
+// Close releases the underlying database handle.
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// withTimeout derives the context for a single store call.
+func (s *sqlStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// bind rewrites a query written with ? placeholders for the active dialect.
+func (s *sqlStore) bind(query string) string {
+	return s.dialect.rebind(query)
+}
+
+func driverForDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return "postgres"
 	}
+	return "sqlite"
+}
 
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		slog.Error("failed to set dialect", "error", err)
-		panic(err)
+func migrate(ctx context.Context, conn *sql.DB, d dialect) error {
+	migrations, err := fs.Sub(embedMigrations, d.migrations)
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
 	}
 
-	if err := goose.Up(db, "migrations"); err != nil {
-		slog.Error("failed to run migrations", "error", err)
-		panic(err)
+	provider, err := goose.NewProvider(d.goose, conn, migrations)
+	if err != nil {
+		return fmt.Errorf("creating migration provider: %w", err)
 	}
 
-	slog.Info("database initialized")
+	if _, err := provider.Up(ctx); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+	return nil
 }
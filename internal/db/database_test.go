@@ -0,0 +1,32 @@
+package db
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDefaultDSN(t *testing.T) {
+	t.Chdir(t.TempDir())
+	touch := func(name string) {
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	// 1. A fresh directory gets calorize.db
+	if got := defaultDSN(); got != DefaultDSN {
+		t.Errorf("Expected %s, got %s", DefaultDSN, got)
+	}
+
+	// 2. An existing test.db from an earlier version is kept
+	touch("test.db")
+	if got := defaultDSN(); got != legacyDSN {
+		t.Errorf("Expected %s, got %s", legacyDSN, got)
+	}
+
+	// 3. Once calorize.db exists it wins
+	touch("calorize.db")
+	if got := defaultDSN(); got != DefaultDSN {
+		t.Errorf("Expected %s, got %s", DefaultDSN, got)
+	}
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBreaksRecipes is returned when an update changes how a food is measured
+// so that recipes following its latest version can no longer convert the
+// amounts they use, such as cups of a food now measured in grams without a
+// density.
+var ErrBreaksRecipes = errors.New("recipes following this food cannot measure the new version")
+
+// RecipeDependent is a current recipe that uses some version of a food family.
+type RecipeDependent struct {
+	Recipe Food `json:"recipe"`
+	// IngredientID is the version the recipe records for the ingredient.
+	IngredientID FoodID `json:"ingredient_id"`
+	FollowLatest bool   `json:"follow_latest"`
+	// Outdated is true when the recipe is pinned to a version that is no
+	// longer current, i.e. it will not see the latest update.
+	Outdated bool `json:"outdated"`
+}
+
+// GetRecipeDependents lists the current recipes visible to userID that include
+// any version of the family id belongs to.
+func (s *sqlStore) GetRecipeDependents(ctx context.Context, userID UserID, id FoodID) ([]RecipeDependent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	dependents, err := s.getRecipeDependents(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	resolver := newNutritionResolver(s)
+	for i := range dependents {
+		if err := resolver.resolveMacros(ctx, &dependents[i].Recipe); err != nil {
+			return nil, err
+		}
+	}
+	return dependents, nil
+}
+
+func (s *sqlStore) getRecipeDependents(ctx context.Context, userID UserID, id FoodID) ([]RecipeDependent, error) {
+	query := `
+		SELECT
+			` + foodColumns("f") + `,
+			ri.ingredient_id, ri.follow_latest, ing.is_current
+		FROM recipe_items ri
+		JOIN foods f ON f.id = ri.recipe_id
+		JOIN foods ing ON ing.id = ri.ingredient_id
+		WHERE ri.family_id = (SELECT family_id FROM foods WHERE id = ?)
+		AND f.is_current = true AND f.deleted_at IS NULL
+		AND (f.creator_id = ? OR f.public = true)
+		ORDER BY f.name
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing recipe dependents: %w", err)
+	}
+	defer rows.Close()
+
+	var dependents []RecipeDependent
+	for rows.Next() {
+		var d RecipeDependent
+		var ingredientCurrent bool
+		err := scanFood(rows, &d.Recipe, &d.IngredientID, &d.FollowLatest, &ingredientCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("scanning recipe dependent: %w", err)
+		}
+		d.Outdated = !d.FollowLatest && !ingredientCurrent
+		dependents = append(dependents, d)
+	}
+	return dependents, rows.Err()
+}
+
+// CascadeFoodUpdate creates new versions of userID's current recipes that are
+// pinned to an older version of id's family, pointing them at id instead.
+// Recipes that include those recipes are cascaded in turn. It returns the new
+// recipe versions; each is saved on its own, so on error the ones created
+// before it are returned with it.
+func (s *sqlStore) CascadeFoodUpdate(ctx context.Context, userID UserID, id FoodID) ([]Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var updated []Food
+	queue := []FoodID{id}
+	for len(queue) > 0 {
+		ingredientID := queue[0]
+		queue = queue[1:]
+
+		ingredient, err := s.getFood(ctx, ingredientID)
+		if err != nil {
+			return updated, err
+		}
+		if ingredient == nil {
+			return updated, ErrFoodNotFound
+		}
+		dependents, err := s.getRecipeDependents(ctx, userID, ingredientID)
+		if err != nil {
+			return updated, err
+		}
+		// A recipe that pins several versions of the ingredient is listed
+		// once per item but gets a single new version.
+		cascaded := make(map[FoodFamilyID]bool)
+		for _, d := range dependents {
+			if !d.Outdated || d.Recipe.CreatorID != userID || cascaded[d.Recipe.FamilyID] {
+				continue
+			}
+			cascaded[d.Recipe.FamilyID] = true
+
+			recipe, err := s.getFood(ctx, d.Recipe.ID)
+			if err != nil {
+				return updated, err
+			}
+			recipe.Ingredients, err = repointItems(recipe.Ingredients, ingredient)
+			if err != nil {
+				return updated, fmt.Errorf("cascading to %s: %w", recipe.Name, err)
+			}
+			recipe.CreatedAt = time.Time{}
+
+			next, err := s.updateFood(ctx, recipe, *recipe)
+			if err != nil {
+				return updated, fmt.Errorf("cascading to %s: %w", recipe.Name, err)
+			}
+			updated = append(updated, *next)
+			queue = append(queue, next.ID)
+		}
+	}
+	return updated, nil
+}
+
+// repointItems points the items pinned to other versions of ingredient's
+// family at ingredient. A recipe lists each version once, so items that end
+// up on the same version are merged, adding their amounts in the first one's
+// unit, or in the ingredient's measurement unit if theirs differ.
+func repointItems(items []RecipeItems, ingredient *Food) ([]RecipeItems, error) {
+	var repointed []RecipeItems
+	for _, item := range items {
+		if item.FamilyID == ingredient.FamilyID && !item.FollowLatest {
+			item.IngredientID = ingredient.ID
+		}
+		merged := false
+		for i := range repointed {
+			first := &repointed[i]
+			if first.IngredientID != item.IngredientID {
+				continue
+			}
+			if first.Unit != item.Unit {
+				a, err := ingredient.measure(first.Amount, first.Unit)
+				if err != nil {
+					return nil, err
+				}
+				b, err := ingredient.measure(item.Amount, item.Unit)
+				if err != nil {
+					return nil, err
+				}
+				first.Amount, first.Unit, item.Amount = a, ingredient.MeasurementUnit, b
+			}
+			first.Amount += item.Amount
+			merged = true
+			break
+		}
+		if !merged {
+			repointed = append(repointed, item)
+		}
+	}
+	return repointed, nil
+}
+
+// checkFollowers makes sure every recipe item following the latest version of
+// food's family can still be measured once food becomes that version. Deleted
+// recipes count too, since log entries still resolve them.
+func (s *sqlStore) checkFollowers(ctx context.Context, food *Food) error {
+	query := `
+		SELECT r.name, ri.amount, ri.unit
+		FROM recipe_items ri
+		JOIN foods r ON r.id = ri.recipe_id
+		WHERE ri.family_id = ? AND ri.follow_latest = true
+		ORDER BY r.name
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), food.FamilyID)
+	if err != nil {
+		return fmt.Errorf("listing recipe followers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, unit string
+		var amount float64
+		if err := rows.Scan(&name, &amount, &unit); err != nil {
+			return fmt.Errorf("scanning recipe follower: %w", err)
+		}
+		if _, err := food.measure(amount, unit); err != nil {
+			return fmt.Errorf("%w: %s uses %g %s (%v)", ErrBreaksRecipes, name, amount, unit, err)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	_ "github.com/glebarez/go-sqlite"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+// dialect captures what differs between the SQLite and PostgreSQL backends.
+type dialect struct {
+	// driver is the database/sql driver name.
+	driver string
+	// goose is the migration dialect.
+	goose goose.Dialect
+	// migrations is the embedded directory holding this backend's migrations.
+	migrations string
+	// rebind converts ? placeholders to the backend's native style.
+	rebind func(query string) string
+	// searchMatches selects (food_id, relevance) from food_search for the
+	// query built by searchQuery, with higher relevance ranking first.
+	searchMatches string
+	// searchQuery turns search terms into a prefix-matching query.
+	searchQuery func(terms []string) string
+}
+
+var dialects = map[string]dialect{
+	"sqlite": {
+		driver:     "sqlite",
+		goose:      goose.DialectSQLite3,
+		migrations: "migrations/sqlite",
+		rebind:     func(query string) string { return query },
+		searchMatches: `
+			SELECT food_id, -bm25(food_search, 0, 10.0, 5.0, 1.0) AS relevance
+			FROM food_search
+			WHERE food_search MATCH ?
+		`,
+		searchQuery: ftsQuery,
+	},
+	"postgres": {
+		driver:     "pgx",
+		goose:      goose.DialectPostgres,
+		migrations: "migrations/postgres",
+		rebind:     rebindDollar,
+		searchMatches: `
+			SELECT fs.food_id, ts_rank(fs.document, q) AS relevance
+			FROM food_search fs, to_tsquery('simple', ?) q
+			WHERE fs.document @@ q
+		`,
+		searchQuery: tsQuery,
+	},
+}
+
+// rebindDollar rewrites ? placeholders as $1, $2, ... Queries in this package
+// never contain a literal question mark, so no quoting rules are needed.
+func rebindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"azule.info/calorize/internal/units"
+)
+
+// KcalPerKg is the energy equivalent of a kilogram of body weight, used to
+// turn weight change into a calorie surplus or deficit.
+const KcalPerKg = 7700
+
+// EnergyEstimate is the energy a user actually expended over a period,
+// worked out from what they logged and how their weight trend moved. Days
+// without logs are incomplete: they are listed and left out of Intake
+// instead of counting as zero calories. Weights are in kg.
+type EnergyEstimate struct {
+	Date           string   `json:"date"`     // YYYY-MM-DD, first day of the period
+	EndDate        string   `json:"end_date"` // YYYY-MM-DD, last day of the period
+	Days           int      `json:"days"`
+	LoggedDays     int      `json:"logged_days"`
+	IncompleteDays []string `json:"incomplete_days"`
+	// Intake is the average calories per logged day.
+	Intake float64 `json:"intake"`
+	// TrendStart and TrendEnd are the weight trend on the first and last days
+	// of the period with a weight reading; WeeklyChange is its slope.
+	TrendStart   *float64 `json:"trend_start,omitempty"`
+	TrendEnd     *float64 `json:"trend_end,omitempty"`
+	WeeklyChange *float64 `json:"weekly_change,omitempty"`
+	// TDEE is the estimated total daily energy expenditure. It is missing
+	// without logged days or without weight readings on two different days.
+	TDEE   *float64      `json:"tdee,omitempty"`
+	Target *EnergyTarget `json:"target,omitempty"`
+}
+
+// EnergyTarget is the daily calorie intake expected to change weight by Rate
+// kg per week.
+type EnergyTarget struct {
+	Rate     float64 `json:"rate"`
+	Calories float64 `json:"calories"`
+}
+
+// GetEnergyEstimate estimates userID's daily energy expenditure over period
+// as their average intake minus the energy equivalent of their weight trend's
+// change. Periods longer than MaxSeriesBuckets days are rejected.
+func (s *sqlStore) GetEnergyEstimate(ctx context.Context, userID UserID, period Period) (EnergyEstimate, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	days, err := period.Buckets("day", time.Monday)
+	if err != nil {
+		return EnergyEstimate{}, err
+	}
+	daily, err := s.statsSeries(ctx, userID, days)
+	if err != nil {
+		return EnergyEstimate{}, err
+	}
+
+	e := EnergyEstimate{
+		Date:           daily[0].Date,
+		EndDate:        daily[len(daily)-1].EndDate,
+		Days:           len(daily),
+		IncompleteDays: []string{},
+	}
+	var intake float64
+	for _, day := range daily {
+		if len(day.Meals) == 0 {
+			e.IncompleteDays = append(e.IncompleteDays, day.Date)
+			continue
+		}
+		e.LoggedDays++
+		intake += day.Calories
+	}
+	if e.LoggedDays > 0 {
+		e.Intake = intake / float64(e.LoggedDays)
+	}
+
+	trends, err := s.getMeasurementTrends(ctx, userID, "weight", period)
+	if err != nil {
+		return EnergyEstimate{}, err
+	}
+	if len(trends) == 0 || len(trends[0].Days) < 2 {
+		return e, nil
+	}
+	weights := trends[0]
+	first, last := weights.Days[0], weights.Days[len(weights.Days)-1]
+	start, err := units.Convert(first.Trend, weights.Unit, "kg", 0)
+	if err != nil {
+		return EnergyEstimate{}, fmt.Errorf("converting weight trend: %w", err)
+	}
+	end, err := units.Convert(last.Trend, weights.Unit, "kg", 0)
+	if err != nil {
+		return EnergyEstimate{}, fmt.Errorf("converting weight trend: %w", err)
+	}
+	from, _ := time.Parse("2006-01-02", first.Date)
+	to, _ := time.Parse("2006-01-02", last.Date)
+	span := to.Sub(from).Hours() / 24
+	dailyChange := (end - start) / span
+
+	weekly := dailyChange * 7
+	e.TrendStart, e.TrendEnd, e.WeeklyChange = &start, &end, &weekly
+	if e.LoggedDays > 0 {
+		tdee := e.Intake - dailyChange*KcalPerKg
+		e.TDEE = &tdee
+	}
+	return e, nil
+}
+
+// SuggestTarget sets Target to the daily intake that would change weight by
+// rate kg per week (negative to lose weight) at the estimated TDEE. It does
+// nothing without a TDEE.
+func (e *EnergyEstimate) SuggestTarget(rate float64) {
+	if e.TDEE == nil {
+		return
+	}
+	e.Target = &EnergyTarget{Rate: rate, Calories: *e.TDEE + rate*KcalPerKg/7}
+}
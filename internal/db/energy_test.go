@@ -0,0 +1,83 @@
+package db
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func testEnergyEstimate(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Test Food") // 100kcal per 100g
+
+	// Two weeks from March 1st; days 3, 7 and 10 have no logs
+	period, err := NewPeriod("last_14_days", time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), time.Monday)
+	if err != nil {
+		t.Fatalf("NewPeriod failed: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	for d := 1; d <= 14; d++ {
+		if d == 3 || d == 7 || d == 10 {
+			continue
+		}
+		createTestLogEntry(t, s, user, food, 2500, day(d))
+	}
+
+	// 1. Without weight readings there is intake but no estimate
+	e, err := s.GetEnergyEstimate(t.Context(), user.ID, period)
+	if err != nil {
+		t.Fatalf("GetEnergyEstimate failed: %v", err)
+	}
+	if e.Date != "2024-03-01" || e.EndDate != "2024-03-14" || e.Days != 14 {
+		t.Errorf("Expected 14 days from 2024-03-01, got %s..%s (%d)", e.Date, e.EndDate, e.Days)
+	}
+	if e.LoggedDays != 11 || len(e.IncompleteDays) != 3 || e.IncompleteDays[0] != "2024-03-03" {
+		t.Errorf("Expected 11 logged days and 3 incomplete ones, got %d and %v", e.LoggedDays, e.IncompleteDays)
+	}
+	// Incomplete days are left out rather than counted as zero
+	if math.Abs(e.Intake-2500) > 1e-6 {
+		t.Errorf("Expected intake 2500, got %v", e.Intake)
+	}
+	if e.TDEE != nil {
+		t.Errorf("Expected no TDEE without weight readings, got %v", *e.TDEE)
+	}
+	e.SuggestTarget(-0.5)
+	if e.Target != nil {
+		t.Errorf("Expected no target without a TDEE, got %+v", e.Target)
+	}
+
+	// 2. Weigh in at 80kg on the 1st and 79kg on the 14th, the latter in lb
+	for _, m := range []Measurement{
+		{UserID: user.ID, Kind: "weight", Value: 80, MeasuredAt: time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)},
+		{UserID: user.ID, Kind: "weight", Value: 79 / 0.45359237, Unit: "lb", MeasuredAt: time.Date(2024, 3, 14, 7, 0, 0, 0, time.UTC)},
+	} {
+		if _, err := s.CreateMeasurement(t.Context(), m); err != nil {
+			t.Fatalf("CreateMeasurement failed: %v", err)
+		}
+	}
+	e, err = s.GetEnergyEstimate(t.Context(), user.ID, period)
+	if err != nil {
+		t.Fatalf("GetEnergyEstimate failed: %v", err)
+	}
+	if e.TDEE == nil || e.TrendStart == nil || e.TrendEnd == nil || e.WeeklyChange == nil {
+		t.Fatalf("Expected a TDEE and weight trend, got %+v", e)
+	}
+	// The trend moves 1-0.9^13 of the way to 79kg over 13 days
+	change := (1 - math.Pow(0.9, 13)) * -1
+	if math.Abs(*e.TrendStart-80) > 1e-6 || math.Abs(*e.TrendEnd-(80+change)) > 1e-6 {
+		t.Errorf("Expected trend 80 to %v kg, got %v to %v", 80+change, *e.TrendStart, *e.TrendEnd)
+	}
+	if math.Abs(*e.WeeklyChange-change/13*7) > 1e-6 {
+		t.Errorf("Expected weekly change %v, got %v", change/13*7, *e.WeeklyChange)
+	}
+	tdee := 2500 - change/13*KcalPerKg
+	if math.Abs(*e.TDEE-tdee) > 1e-6 {
+		t.Errorf("Expected TDEE %v, got %v", tdee, *e.TDEE)
+	}
+
+	// 3. Losing 0.5kg a week takes 550kcal a day below TDEE
+	e.SuggestTarget(-0.5)
+	if e.Target == nil || e.Target.Rate != -0.5 || math.Abs(e.Target.Calories-(tdee-550)) > 1e-6 {
+		t.Errorf("Expected target %v, got %+v", tdee-550, e.Target)
+	}
+}
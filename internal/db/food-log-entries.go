@@ -1,36 +1,164 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func GetFoodLogEntries(userID UserID, date time.Time) ([]FoodLogEntry, error) {
+// LogFilter selects food log entries. From is inclusive and To exclusive; a
+// zero bound leaves that side of the range open. An empty MealTag matches
+// every meal. With IncludeFood set, each entry carries a summary of its food
+// scaled to the logged amount.
+type LogFilter struct {
+	From        time.Time
+	To          time.Time
+	MealTag     string
+	IncludeFood bool
+}
+
+// DayFilter selects the entries logged on the calendar day of date in date's
+// own location, so a user's day is bounded by their local midnights.
+func DayFilter(date time.Time) LogFilter {
+	y, m, d := date.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	return LogFilter{From: start, To: start.AddDate(0, 0, 1)}
+}
+
+// GetFoodLogEntries lists userID's entries matching filter. opts.Sort is
+// "logged_at" (the default) or "created_at", by the time-ordered entry id;
+// both are oldest first.
+func (s *sqlStore) GetFoodLogEntries(ctx context.Context, userID UserID, filter LogFilter, opts PageOptions) (Page[FoodLogEntry], error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sort := opts.Sort
+	if sort == "" {
+		sort = "logged_at"
+	}
+	after, err := decodeCursor(opts.Cursor, sort)
+	if err != nil {
+		return Page[FoodLogEntry]{}, err
+	}
+	var afterID FoodLogEntryID
+	if after != nil {
+		id, err := after.id()
+		if err != nil {
+			return Page[FoodLogEntry]{}, err
+		}
+		afterID = FoodLogEntryID(id)
+	}
+
+	where := "user_id = ? AND deleted_at IS NULL"
+	args := []any{userID}
+	if !filter.From.IsZero() {
+		where += " AND logged_at >= ?"
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where += " AND logged_at < ?"
+		args = append(args, filter.To.UTC())
+	}
+	if filter.MealTag != "" {
+		where += " AND meal_tag = ?"
+		args = append(args, filter.MealTag)
+	}
+
+	var order string
+	switch sort {
+	case "logged_at":
+		order = "logged_at, id"
+		if after != nil {
+			loggedAt, err := time.Parse(time.RFC3339Nano, after.Key)
+			if err != nil {
+				return Page[FoodLogEntry]{}, ErrInvalidCursor
+			}
+			where += " AND (logged_at > ? OR (logged_at = ? AND id > ?))"
+			args = append(args, loggedAt.UTC(), loggedAt.UTC(), afterID)
+		}
+	case "created_at":
+		order = "id"
+		if after != nil {
+			where += " AND id > ?"
+			args = append(args, afterID)
+		}
+	default:
+		return Page[FoodLogEntry]{}, invalidSort(sort)
+	}
+
+	limit := opts.limit()
 	query := `
-		SELECT id, user_id, food_id, amount, meal_tag, logged_at, created_at, deleted_at 
-		FROM food_log_entries 
-		WHERE user_id = ? AND date(logged_at) = date(?) AND deleted_at IS NULL
+		SELECT id, user_id, food_id, amount, unit, portion_id, quantity, meal_tag, logged_at, created_at, deleted_at
+		FROM food_log_entries
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ?
 	`
-	rows, err := db.Query(query, userID, date)
+	rows, err := s.db.QueryContext(ctx, s.bind(query), append(args, limit+1)...)
 	if err != nil {
-		return nil, fmt.Errorf("listing food log entries: %w", err)
+		return Page[FoodLogEntry]{}, fmt.Errorf("listing food log entries: %w", err)
 	}
 	defer rows.Close()
 
 	var entries []FoodLogEntry
 	for rows.Next() {
 		var entry FoodLogEntry
-		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.FoodID, &entry.Amount, &entry.MealTag, &entry.LoggedAt, &entry.CreatedAt, &entry.DeletedAt); err != nil {
-			return nil, fmt.Errorf("scanning food log entry: %w", err)
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.FoodID, &entry.Amount, &entry.Unit, &entry.PortionID, &entry.Quantity, &entry.MealTag, &entry.LoggedAt, &entry.CreatedAt, &entry.DeletedAt); err != nil {
+			return Page[FoodLogEntry]{}, fmt.Errorf("scanning food log entry: %w", err)
 		}
 		entries = append(entries, entry)
 	}
-	return entries, nil
+	if err := rows.Err(); err != nil {
+		return Page[FoodLogEntry]{}, fmt.Errorf("listing food log entries: %w", err)
+	}
+
+	page := newPage(entries, limit, func(last FoodLogEntry) cursor {
+		c := cursor{Sort: sort, ID: uuid.UUID(last.ID).String()}
+		if sort == "logged_at" {
+			c.Key = last.LoggedAt.UTC().Format(time.RFC3339Nano)
+		}
+		return c
+	})
+	if filter.IncludeFood {
+		if err := s.embedLoggedFoods(ctx, page.Items); err != nil {
+			return Page[FoodLogEntry]{}, err
+		}
+	}
+	return page, nil
+}
+
+// embedLoggedFoods sets Food on each entry to its food's name and nutrition
+// for the logged amount. Entries whose food no longer exists are left bare.
+func (s *sqlStore) embedLoggedFoods(ctx context.Context, entries []FoodLogEntry) error {
+	resolver := newNutritionResolver(s)
+	for i := range entries {
+		f, err := resolver.resolve(ctx, entries[i].FoodID)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		amount, err := f.measure(entries[i].Amount, entries[i].Unit)
+		if err != nil {
+			return err
+		}
+		entries[i].Food = &LoggedFood{
+			Name:      f.Name,
+			Brand:     f.Brand,
+			Nutrition: *f.scaledNutrition(servingsOf(f, amount)),
+		}
+	}
+	return nil
 }
 
-func CreateFoodLogEntry(entry FoodLogEntry) (*FoodLogEntry, error) {
+func (s *sqlStore) CreateFoodLogEntry(ctx context.Context, entry FoodLogEntry) (*FoodLogEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	newID, err := uuid.NewV7()
 	if err != nil {
 		return nil, err
@@ -39,9 +167,15 @@ func CreateFoodLogEntry(entry FoodLogEntry) (*FoodLogEntry, error) {
 	if entry.CreatedAt.IsZero() {
 		entry.CreatedAt = time.Now()
 	}
+	if entry.LoggedAt.IsZero() {
+		entry.LoggedAt = entry.CreatedAt
+	}
+	if err := s.checkLogEntry(ctx, &entry); err != nil {
+		return nil, err
+	}
 
-	_, err = db.Exec("INSERT INTO food_log_entries (id, user_id, food_id, amount, meal_tag, logged_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		newID, entry.UserID, entry.FoodID, entry.Amount, entry.MealTag, entry.LoggedAt, entry.CreatedAt)
+	_, err = s.db.ExecContext(ctx, s.bind("INSERT INTO food_log_entries (id, user_id, food_id, amount, unit, portion_id, quantity, meal_tag, logged_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		newID, entry.UserID, entry.FoodID, entry.Amount, entry.Unit, entry.PortionID, entry.Quantity, entry.MealTag, entry.LoggedAt.UTC(), entry.CreatedAt.UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -51,9 +185,16 @@ func CreateFoodLogEntry(entry FoodLogEntry) (*FoodLogEntry, error) {
 
 }
 
-func UpdateFoodLogEntry(entry FoodLogEntry) (*FoodLogEntry, error) {
-	_, err := db.Exec("UPDATE food_log_entries SET food_id = ?, amount = ?, meal_tag = ?, logged_at = ? WHERE id = ? AND user_id = ?",
-		entry.FoodID, entry.Amount, entry.MealTag, entry.LoggedAt, entry.ID, entry.UserID)
+func (s *sqlStore) UpdateFoodLogEntry(ctx context.Context, entry FoodLogEntry) (*FoodLogEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.checkLogEntry(ctx, &entry); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.ExecContext(ctx, s.bind("UPDATE food_log_entries SET food_id = ?, amount = ?, unit = ?, portion_id = ?, quantity = ?, meal_tag = ?, logged_at = ? WHERE id = ? AND user_id = ?"),
+		entry.FoodID, entry.Amount, entry.Unit, entry.PortionID, entry.Quantity, entry.MealTag, entry.LoggedAt.UTC(), entry.ID, entry.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -61,10 +202,38 @@ func UpdateFoodLogEntry(entry FoodLogEntry) (*FoodLogEntry, error) {
 
 }
 
-func DeleteFoodLogEntry(id FoodLogEntryID, userID UserID) error {
-	_, err := db.Exec("DELETE FROM food_log_entries WHERE id = ? AND user_id = ?", id, userID)
+func (s *sqlStore) DeleteFoodLogEntry(ctx context.Context, id FoodLogEntryID, userID UserID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM food_log_entries WHERE id = ? AND user_id = ?"), id, userID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// checkLogEntry makes sure the entry's user may see its food, so nobody can
+// log another user's private food, and fills in a portion's amount and unit.
+// It then rejects entries whose unit cannot be converted into the food's
+// measurement unit, so stats never meet them.
+func (s *sqlStore) checkLogEntry(ctx context.Context, entry *FoodLogEntry) error {
+	f, err := s.getFood(ctx, entry.FoodID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkFoodView(ctx, f, entry.UserID); err != nil {
+		if errors.Is(err, ErrFoodNotFound) {
+			return fmt.Errorf("%w: %s", ErrFoodNotFound, uuid.UUID(entry.FoodID))
+		}
+		return err
+	}
+	if err := s.applyPortion(ctx, entry); err != nil {
+		return err
+	}
+	if entry.Unit == "" {
+		return nil
+	}
+	_, err = f.measure(entry.Amount, entry.Unit)
+	return err
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func testFoodLogEntries(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Oats")
+
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+
+	// 1. Create entries on two days
+	entry := createTestLogEntry(t, s, user, food, 40, today)
+	createTestLogEntry(t, s, user, food, 60, yesterday)
+
+	// 2. Only today's entry is returned for today
+	entriesPage, err := s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(today), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries failed: %v", err)
+	}
+	entries := entriesPage.Items
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].ID != entry.ID {
+		t.Errorf("Entry ID mismatch")
+	}
+	if entries[0].Amount != 40 {
+		t.Errorf("Expected amount 40, got %f", entries[0].Amount)
+	}
+
+	// 3. Update
+	entry.Amount = 80
+	entry.MealTag = "lunch"
+	if _, err := s.UpdateFoodLogEntry(t.Context(), *entry); err != nil {
+		t.Fatalf("UpdateFoodLogEntry failed: %v", err)
+	}
+	entriesPage, err = s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(today), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (after update) failed: %v", err)
+	}
+	entries = entriesPage.Items
+	if len(entries) != 1 || entries[0].Amount != 80 || entries[0].MealTag != "lunch" {
+		t.Errorf("Update not reflected: %+v", entries)
+	}
+
+	// 4. Another user cannot delete it
+	other := createTestUser(t, s)
+	if err := s.DeleteFoodLogEntry(t.Context(), entry.ID, other.ID); err != nil {
+		t.Fatalf("DeleteFoodLogEntry (other user) failed: %v", err)
+	}
+	entriesPage, err = s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(today), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries failed: %v", err)
+	}
+	entries = entriesPage.Items
+	if len(entries) != 1 {
+		t.Errorf("Entry deleted by another user")
+	}
+
+	// 5. Delete
+	if err := s.DeleteFoodLogEntry(t.Context(), entry.ID, user.ID); err != nil {
+		t.Fatalf("DeleteFoodLogEntry failed: %v", err)
+	}
+	entriesPage, err = s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(today), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (after delete) failed: %v", err)
+	}
+	entries = entriesPage.Items
+	if len(entries) != 0 {
+		t.Errorf("Expected 0 entries after delete, got %d", len(entries))
+	}
+}
+
+func testFoodLogFilters(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Rice")
+
+	base := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// 1. Log across three days, dinner on the last
+	first := createTestLogEntry(t, s, user, food, 50, base)
+	createTestLogEntry(t, s, user, food, 150, base.AddDate(0, 0, 1))
+	dinner, err := s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: food.ID, Amount: 200, MealTag: "dinner", LoggedAt: base.AddDate(0, 0, 2)})
+	if err != nil {
+		t.Fatalf("CreateFoodLogEntry failed: %v", err)
+	}
+
+	// 2. A past day is listed on its own
+	page, err := s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(base), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != first.ID {
+		t.Errorf("Expected only the first entry, got %+v", page.Items)
+	}
+
+	// 3. A range covers every day in it, ordered by logged_at
+	filter := LogFilter{From: DayFilter(base).From, To: DayFilter(base.AddDate(0, 0, 2)).To}
+	page, err = s.GetFoodLogEntries(t.Context(), user.ID, filter, PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (range) failed: %v", err)
+	}
+	if len(page.Items) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(page.Items))
+	}
+	for i := 1; i < len(page.Items); i++ {
+		if page.Items[i].LoggedAt.Before(page.Items[i-1].LoggedAt) {
+			t.Errorf("Entries not ordered by logged_at: %+v", page.Items)
+		}
+	}
+
+	// 4. Meal tag narrows the range
+	filter.MealTag = "dinner"
+	page, err = s.GetFoodLogEntries(t.Context(), user.ID, filter, PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (meal tag) failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != dinner.ID {
+		t.Errorf("Expected only the dinner entry, got %+v", page.Items)
+	}
+	if page.Items[0].Food != nil {
+		t.Errorf("Expected no embedded food without IncludeFood")
+	}
+
+	// 5. The embedded food is scaled to the logged amount (200g of 100kcal/100g)
+	filter.IncludeFood = true
+	page, err = s.GetFoodLogEntries(t.Context(), user.ID, filter, PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (include food) failed: %v", err)
+	}
+	logged := page.Items[0].Food
+	if logged == nil {
+		t.Fatalf("Expected an embedded food")
+	}
+	if logged.Name != "Rice" || logged.Calories != 200 || logged.Protein != 20 {
+		t.Errorf("Expected Rice with 200 kcal and 20g protein, got %+v", logged)
+	}
+
+	// 6. An entry without logged_at is logged now
+	now, err := s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: food.ID, Amount: 10})
+	if err != nil {
+		t.Fatalf("CreateFoodLogEntry (no logged_at) failed: %v", err)
+	}
+	if now.LoggedAt.IsZero() {
+		t.Errorf("Expected logged_at to default to now")
+	}
+
+	// 7. Days follow the date's time zone: 03:00 UTC on the 10th is the
+	// evening of the 9th in Los Angeles
+	la, err := LoadTimeZone("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadTimeZone failed: %v", err)
+	}
+	late := createTestLogEntry(t, s, user, food, 30, time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC))
+	page, err = s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(time.Date(2024, 3, 9, 0, 0, 0, 0, la)), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries (time zone) failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != late.ID {
+		t.Errorf("Expected only the late entry on the 9th in Los Angeles, got %+v", page.Items)
+	}
+}
@@ -1,62 +1,206 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func GetFoods(userID UserID) ([]Food, error) {
+// ErrFoodNotFound is returned when an operation needs a food that does not
+// exist.
+var ErrFoodNotFound = errors.New("food not found")
+
+// ErrVersionConflict is returned when a change targets a food version that is
+// no longer current.
+var ErrVersionConflict = errors.New("food version is no longer current")
+
+// foodColumnNames are the foods columns read by scanFood, in order.
+var foodColumnNames = []string{
+	"id", "creator_id", "family_id", "version", "is_current", "name",
+	"calories", "protein", "carbs", "fat", "type",
+	"measurement_unit", "measurement_amount", "public", "created_at", "deleted_at",
+	"yield_weight", "servings", "density", "brand",
+	"source_family_id", "source_version",
+}
+
+// foodColumns returns the select list for scanFood, qualified with alias
+// when the query joins foods to other tables.
+func foodColumns(alias string) string {
+	if alias == "" {
+		return strings.Join(foodColumnNames, ", ")
+	}
+	return alias + "." + strings.Join(foodColumnNames, ", "+alias+".")
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFood scans a row selected with foodColumns into f. Any extra
+// destinations are scanned from the columns that follow.
+func scanFood(row rowScanner, f *Food, extra ...any) error {
+	dest := []any{
+		&f.ID, &f.CreatorID, &f.FamilyID, &f.Version, &f.IsCurrent, &f.Name,
+		&f.Calories, &f.Protein, &f.Carbs, &f.Fat, &f.Type,
+		&f.MeasurementUnit, &f.MeasurementAmount, &f.Public, &f.CreatedAt, &f.DeletedAt,
+		&f.YieldWeight, &f.Servings, &f.Density, &f.Brand,
+		&f.SourceFamilyID, &f.SourceVersion,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// GetFoods lists the current foods in scope for userID. opts.Sort is "name"
+// (the default), "created_at" (newest first, by when the family's first
+// version was created, so updates do not reorder it) or "most_logged" (by how
+// often userID has logged any version). Recipe macros are rolled up from
+// their ingredients, as GetFood reports them.
+func (s *sqlStore) GetFoods(ctx context.Context, userID UserID, scope FoodScope, opts PageOptions) (Page[Food], error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	visible, visibleArgs, err := scope.condition(userID)
+	if err != nil {
+		return Page[Food]{}, err
+	}
+
+	sort := opts.Sort
+	if sort == "" {
+		sort = "name"
+	}
+	after, err := decodeCursor(opts.Cursor, sort)
+	if err != nil {
+		return Page[Food]{}, err
+	}
+	// Ties are broken by family, whose id is its first version's, so foods
+	// keep their creation order when they are updated.
+	var afterID FoodFamilyID
+	if after != nil {
+		id, err := after.id()
+		if err != nil {
+			return Page[Food]{}, err
+		}
+		afterID = FoodFamilyID(id)
+	}
+
+	var args []any
+	logCount, join := "0", ""
+	if sort == "most_logged" {
+		logCount = "COALESCE(logged.n, 0)"
+		join = `
+			LEFT JOIN (
+				SELECT lf.family_id, COUNT(*) AS n
+				FROM food_log_entries le
+				JOIN foods lf ON lf.id = le.food_id
+				WHERE le.user_id = ? AND le.deleted_at IS NULL
+				GROUP BY lf.family_id
+			) logged ON logged.family_id = f.family_id`
+		args = append(args, userID)
+	}
+	where := visible + " AND f.is_current = true AND f.deleted_at IS NULL"
+	args = append(args, visibleArgs...)
+
+	var order string
+	switch sort {
+	case "name":
+		order = "f.name, f.family_id"
+		if after != nil {
+			where += " AND (f.name > ? OR (f.name = ? AND f.family_id > ?))"
+			args = append(args, after.Key, after.Key, afterID)
+		}
+	case "created_at":
+		order = "f.family_id DESC"
+		if after != nil {
+			where += " AND f.family_id < ?"
+			args = append(args, afterID)
+		}
+	case "most_logged":
+		order = logCount + " DESC, f.family_id"
+		if after != nil {
+			n, err := strconv.Atoi(after.Key)
+			if err != nil {
+				return Page[Food]{}, ErrInvalidCursor
+			}
+			where += " AND (" + logCount + " < ? OR (" + logCount + " = ? AND f.family_id > ?))"
+			args = append(args, n, n, afterID)
+		}
+	default:
+		return Page[Food]{}, invalidSort(sort)
+	}
+
+	limit := opts.limit()
 	query := `
-		SELECT 
-			id, creator_id, family_id, version, is_current, name, 
-			calories, protein, carbs, fat, type, 
-			measurement_unit, measurement_amount, public, created_at, deleted_at 
-		FROM foods 
-		WHERE (creator_id = ? OR public = true) AND is_current = true AND deleted_at IS NULL
+		SELECT ` + foodColumns("f") + `, ` + logCount + `
+		FROM foods f` + join + `
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ?
 	`
-	rows, err := db.Query(query, userID)
+	rows, err := s.db.QueryContext(ctx, s.bind(query), append(args, limit+1)...)
 	if err != nil {
-		return nil, fmt.Errorf("listing foods: %w", err)
+		return Page[Food]{}, fmt.Errorf("listing foods: %w", err)
 	}
 	defer rows.Close()
 
 	var foods []Food
+	counts := make(map[FoodID]int)
 	for rows.Next() {
 		var f Food
-		err := rows.Scan(
-			&f.ID, &f.CreatorID, &f.FamilyID, &f.Version, &f.IsCurrent, &f.Name,
-			&f.Calories, &f.Protein, &f.Carbs, &f.Fat, &f.Type,
-			&f.MeasurementUnit, &f.MeasurementAmount, &f.Public, &f.CreatedAt, &f.DeletedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scanning food: %w", err)
+		var n int
+		if err := scanFood(rows, &f, &n); err != nil {
+			return Page[Food]{}, fmt.Errorf("scanning food: %w", err)
 		}
 		foods = append(foods, f)
+		counts[f.ID] = n
+	}
+	if err := rows.Err(); err != nil {
+		return Page[Food]{}, fmt.Errorf("listing foods: %w", err)
+	}
+	resolver := newNutritionResolver(s)
+	for i := range foods {
+		if err := resolver.resolveMacros(ctx, &foods[i]); err != nil {
+			return Page[Food]{}, err
+		}
 	}
-	return foods, nil
+
+	return newPage(foods, limit, func(last Food) cursor {
+		c := cursor{Sort: sort, ID: uuid.UUID(last.FamilyID).String()}
+		switch sort {
+		case "name":
+			c.Key = last.Name
+		case "most_logged":
+			c.Key = strconv.Itoa(counts[last.ID])
+		}
+		return c
+	}), nil
+}
+
+// GetFood returns a single food version. Recipes have their macros and
+// nutrients rolled up from their ingredients.
+func (s *sqlStore) GetFood(ctx context.Context, id FoodID) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return newNutritionResolver(s).resolve(ctx, id)
 }
 
-func GetFood(id FoodID) (*Food, error) {
+// getFood loads a food version exactly as stored, with its nutrients and
+// ingredients. It returns nil if the food does not exist.
+func (s *sqlStore) getFood(ctx context.Context, id FoodID) (*Food, error) {
 	query := `
-		SELECT
-			id, creator_id, family_id, version, is_current, name,
-			calories, protein, carbs, fat, type,
-			measurement_unit, measurement_amount, public, created_at, deleted_at
+		SELECT ` + foodColumns("") + `
 		FROM foods
 		WHERE id = ?
 	`
-	row := db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, s.bind(query), id)
 
 	var f Food
-	err := row.Scan(
-		&f.ID, &f.CreatorID, &f.FamilyID, &f.Version, &f.IsCurrent, &f.Name,
-		&f.Calories, &f.Protein, &f.Carbs, &f.Fat, &f.Type,
-		&f.MeasurementUnit, &f.MeasurementAmount, &f.Public, &f.CreatedAt, &f.DeletedAt,
-	)
+	err := scanFood(row, &f)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Or specific error
@@ -70,7 +214,7 @@ func GetFood(id FoodID) (*Food, error) {
 		FROM food_nutrients
 		WHERE food_id = ?
 	`
-	nRows, err := db.Query(nutrientsQuery, f.ID)
+	nRows, err := s.db.QueryContext(ctx, s.bind(nutrientsQuery), f.ID)
 	if err != nil {
 		return nil, fmt.Errorf("getting food nutrients: %w", err)
 	}
@@ -84,13 +228,20 @@ func GetFood(id FoodID) (*Food, error) {
 		f.Nutrients = append(f.Nutrients, n)
 	}
 
-	// Fetch ingredients
+	// Fetch ingredients. Items that follow the latest version report the
+	// family's current version, falling back to the recorded one if the
+	// family has since been deleted.
 	ingredientsQuery := `
-		SELECT recipe_id, ingredient_id, amount
-		FROM recipe_items
-		WHERE recipe_id = ?
+		SELECT
+			ri.recipe_id,
+			CASE WHEN ri.follow_latest THEN COALESCE(cur.id, ri.ingredient_id) ELSE ri.ingredient_id END,
+			ri.family_id, ri.follow_latest, ri.amount, ri.unit
+		FROM recipe_items ri
+		LEFT JOIN foods cur ON ri.follow_latest
+			AND cur.family_id = ri.family_id AND cur.is_current = true AND cur.deleted_at IS NULL
+		WHERE ri.recipe_id = ?
 	`
-	iRows, err := db.Query(ingredientsQuery, f.ID)
+	iRows, err := s.db.QueryContext(ctx, s.bind(ingredientsQuery), f.ID)
 	if err != nil {
 		return nil, fmt.Errorf("getting food ingredients: %w", err)
 	}
@@ -98,35 +249,39 @@ func GetFood(id FoodID) (*Food, error) {
 
 	for iRows.Next() {
 		var i RecipeItems
-		if err := iRows.Scan(&i.RecipeID, &i.IngredientID, &i.Amount); err != nil {
+		if err := iRows.Scan(&i.RecipeID, &i.IngredientID, &i.FamilyID, &i.FollowLatest, &i.Amount, &i.Unit); err != nil {
 			return nil, fmt.Errorf("scanning ingredient: %w", err)
 		}
 		f.Ingredients = append(f.Ingredients, i)
 	}
 
+	f.Portions, err = s.getFoodPortions(ctx, f.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &f, nil
 }
 
-func GetFoodVersions(id FoodID) ([]Food, error) {
-	var familyID FoodFamilyID
-	err := db.QueryRow("SELECT family_id FROM foods WHERE id = ?", id).Scan(&familyID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("getting food family: %w", err)
-	}
+// GetFoodVersions lists the live versions of id's family that userID can see,
+// newest first. Once a family is unpublished, other users only see the
+// versions they logged.
+func (s *sqlStore) GetFoodVersions(ctx context.Context, userID UserID, id FoodID) ([]Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
 	query := `
-		SELECT 
-			id, creator_id, family_id, version, is_current, name, 
-			calories, protein, carbs, fat, type, 
-			measurement_unit, measurement_amount, public, created_at, deleted_at 
-		FROM foods 
-		WHERE family_id = ? AND deleted_at IS NULL
-		ORDER BY version DESC
+		SELECT ` + foodColumns("f") + `
+		FROM foods f
+		WHERE f.family_id = (SELECT family_id FROM foods WHERE id = ?)
+		AND f.deleted_at IS NULL
+		AND (f.public = true OR f.creator_id = ? OR EXISTS (
+			SELECT 1 FROM food_log_entries le
+			WHERE le.food_id = f.id AND le.user_id = ? AND le.deleted_at IS NULL
+		))
+		ORDER BY f.version DESC
 	`
-	rows, err := db.Query(query, familyID)
+	rows, err := s.db.QueryContext(ctx, s.bind(query), id, userID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("listing food versions: %w", err)
 	}
@@ -135,25 +290,19 @@ func GetFoodVersions(id FoodID) ([]Food, error) {
 	var versions []Food
 	for rows.Next() {
 		var f Food
-		err := rows.Scan(
-			&f.ID, &f.CreatorID, &f.FamilyID, &f.Version, &f.IsCurrent, &f.Name,
-			&f.Calories, &f.Protein, &f.Carbs, &f.Fat, &f.Type,
-			&f.MeasurementUnit, &f.MeasurementAmount, &f.Public, &f.CreatedAt, &f.DeletedAt,
-		)
+		err := scanFood(rows, &f)
 		if err != nil {
 			return nil, fmt.Errorf("scanning food version: %w", err)
 		}
 		versions = append(versions, f)
 	}
-	return versions, nil
+	return versions, rows.Err()
 }
 
-func CreateFood(food Food) (*Food, error) {
-	if len(food.Ingredients) > 0 {
-		food.Type = "recipe"
-	} else if food.Type == "" {
-		food.Type = "food"
-	}
+func (s *sqlStore) CreateFood(ctx context.Context, food Food) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	if food.ID == FoodID(uuid.Nil) {
 		id, err := uuid.NewV7()
 		if err != nil {
@@ -169,55 +318,18 @@ func CreateFood(food Food) (*Food, error) {
 	if food.CreatedAt.IsZero() {
 		food.CreatedAt = time.Now()
 	}
+	if err := s.prepareFood(ctx, &food); err != nil {
+		return nil, err
+	}
 
-	tx, err := db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	query := `
-		INSERT INTO foods (
-			id, creator_id, family_id, version, is_current, name, 
-			calories, protein, carbs, fat, type, 
-			measurement_unit, measurement_amount, public, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	_, err = tx.Exec(query,
-		food.ID, food.CreatorID, food.FamilyID, food.Version, food.IsCurrent, food.Name,
-		food.Calories, food.Protein, food.Carbs, food.Fat, food.Type,
-		food.MeasurementUnit, food.MeasurementAmount, food.Public, food.CreatedAt,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("inserting food: %w", err)
-	}
-
-	// Insert nutrients
-	stmt, err := tx.Prepare("INSERT INTO food_nutrients (food_id, name, amount, unit) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		return nil, fmt.Errorf("preparing nutrients stmt: %w", err)
-	}
-	defer stmt.Close()
-
-	for _, n := range food.Nutrients {
-		if _, err := stmt.Exec(food.ID, n.Name, n.Amount, n.Unit); err != nil {
-			return nil, fmt.Errorf("inserting nutrient: %w", err)
-		}
-	}
-
-	// Insert ingredients
-	if len(food.Ingredients) > 0 {
-		istmt, err := tx.Prepare("INSERT INTO recipe_items (recipe_id, ingredient_id, amount) VALUES (?, ?, ?)")
-		if err != nil {
-			return nil, fmt.Errorf("preparing ingredients stmt: %w", err)
-		}
-		defer istmt.Close()
-
-		for _, i := range food.Ingredients {
-			if _, err := istmt.Exec(food.ID, i.IngredientID, i.Amount); err != nil {
-				return nil, fmt.Errorf("inserting ingredient: %w", err)
-			}
-		}
+	if err := s.insertFood(ctx, tx, food); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -227,13 +339,32 @@ func CreateFood(food Food) (*Food, error) {
 	return &food, nil
 }
 
-func UpdateFood(id FoodID, food Food) (*Food, error) {
-	current, err := GetFood(id)
+// UpdateFood creates a new version of id's family from food. id is the
+// version the change is based on; if it is no longer the family's current
+// version, because someone else updated the food since, ErrVersionConflict is
+// returned. food.CreatorID is the user making the change, who must have
+// created the food.
+func (s *sqlStore) UpdateFood(ctx context.Context, id FoodID, food Food) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	current, err := s.getFood(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if current == nil {
-		return nil, fmt.Errorf("food not found")
+	if err := checkFoodAccess(current, food.CreatorID, EditFood); err != nil {
+		return nil, err
+	}
+	return s.updateFood(ctx, current, food)
+}
+
+// updateFood creates a new version of current's family from food without
+// checking who is making the change. It is for changes the store makes on
+// the creator's behalf, such as cascading an ingredient update; the creator
+// is always kept.
+func (s *sqlStore) updateFood(ctx context.Context, current *Food, food Food) (*Food, error) {
+	if !current.IsCurrent {
+		return nil, fmt.Errorf("%w: version %d", ErrVersionConflict, current.Version)
 	}
 
 	newID, err := uuid.NewV7()
@@ -244,94 +375,230 @@ func UpdateFood(id FoodID, food Food) (*Food, error) {
 	food.FamilyID = current.FamilyID
 	food.Version = current.Version + 1
 	food.IsCurrent = true
-	if len(food.Ingredients) > 0 {
-		food.Type = "recipe"
-	} else if food.Type == "" {
-		food.Type = "food"
-	}
 	if food.CreatedAt.IsZero() {
 		food.CreatedAt = time.Now()
 	}
-	// Keep creator unless specified
-	if food.CreatorID == UserID(uuid.Nil) {
-		food.CreatorID = current.CreatorID
+	food.CreatorID = current.CreatorID
+	// Visibility belongs to the family and only changes with SetFoodPublic.
+	food.Public = current.Public
+	food.SourceFamilyID, food.SourceVersion = current.SourceFamilyID, current.SourceVersion
+	// Carry portions forward unless specified, dropping any that no longer
+	// convert to the new measurement unit.
+	if food.Portions == nil {
+		for _, p := range current.Portions {
+			if _, err := food.measure(p.Amount, p.Unit); err == nil {
+				food.Portions = append(food.Portions, p)
+			}
+		}
+	}
+	if err := s.prepareFood(ctx, &food); err != nil {
+		return nil, err
+	}
+	if err := s.checkFollowers(ctx, &food); err != nil {
+		return nil, err
 	}
 
-	tx, err := db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("starting transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec("UPDATE foods SET is_current = false WHERE id = ?", current.ID)
+	// Only one update can retire the current version; a concurrent one
+	// finds it already retired and conflicts instead of forking the family.
+	res, err := tx.ExecContext(ctx, s.bind("UPDATE foods SET is_current = false WHERE id = ? AND is_current = true"), current.ID)
 	if err != nil {
 		return nil, fmt.Errorf("deprecating old version: %w", err)
 	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("deprecating old version: %w", err)
+	} else if n == 0 {
+		return nil, fmt.Errorf("%w: version %d", ErrVersionConflict, current.Version)
+	}
+
+	if err := s.insertFood(ctx, tx, food); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing update: %w", err)
+	}
+
+	return &food, nil
+}
+
+// prepareFood fills in the type, gives portions fresh ids and, for recipes, points follow-latest items
+// at their family's current version, validates the ingredient graph and
+// replaces the macros and nutrients with the ingredient totals. The computed
+// yield fields are set so the returned food matches what GetFood reports.
+func (s *sqlStore) prepareFood(ctx context.Context, food *Food) error {
+	if food.YieldWeight < 0 || food.Servings < 0 {
+		return ErrInvalidYield
+	}
+	if food.Density < 0 {
+		return ErrInvalidDensity
+	}
+	for i := range food.Portions {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return fmt.Errorf("generating portion id: %w", err)
+		}
+		food.Portions[i].ID = FoodPortionID(id)
+		food.Portions[i].FoodID = food.ID
+		if food.Portions[i].CreatedAt.IsZero() {
+			food.Portions[i].CreatedAt = food.CreatedAt
+		}
+	}
+	if len(food.Ingredients) > 0 {
+		food.Type = "recipe"
+	} else if food.Type == "" {
+		food.Type = "food"
+	}
+	if len(food.Ingredients) == 0 {
+		food.applyYield(0)
+		return nil
+	}
 
+	for i, item := range food.Ingredients {
+		if !item.FollowLatest {
+			continue
+		}
+		latest, err := s.latestVersion(ctx, item.IngredientID)
+		if err != nil {
+			return err
+		}
+		food.Ingredients[i].IngredientID = latest
+	}
+
+	if err := s.checkIngredientGraph(ctx, food); err != nil {
+		return err
+	}
+
+	resolver := newNutritionResolver(s)
+	total, err := resolver.rollup(ctx, food.Ingredients)
+	if err != nil {
+		return err
+	}
+	food.Calories, food.Protein, food.Carbs, food.Fat = total.Calories, total.Protein, total.Carbs, total.Fat
+	food.Nutrients = total.Nutrients
+	for i := range food.Nutrients {
+		food.Nutrients[i].FoodID = food.ID
+	}
+	food.applyYield(total.RawWeight)
+	for i, item := range food.Ingredients {
+		ing, err := resolver.resolve(ctx, item.IngredientID)
+		if err != nil {
+			return err
+		}
+		food.Ingredients[i].RecipeID = RecipeID(food.ID)
+		food.Ingredients[i].FamilyID = ing.FamilyID
+	}
+	return nil
+}
+
+// insertFood writes a new food version with its portions, nutrients and
+// ingredients, and adds it to the search index.
+// Recipe nutrients are derived on read, so only the rolled-up macros are
+// stored for them.
+func (s *sqlStore) insertFood(ctx context.Context, tx *sql.Tx, food Food) error {
 	query := `
 		INSERT INTO foods (
-			id, creator_id, family_id, version, is_current, name, 
-			calories, protein, carbs, fat, type, 
-			measurement_unit, measurement_amount, public, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			id, creator_id, family_id, version, is_current, name,
+			calories, protein, carbs, fat, type,
+			measurement_unit, measurement_amount, public, created_at,
+			yield_weight, servings, density, brand,
+			source_family_id, source_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = tx.Exec(query,
+	_, err := tx.ExecContext(ctx, s.bind(query),
 		food.ID, food.CreatorID, food.FamilyID, food.Version, food.IsCurrent, food.Name,
 		food.Calories, food.Protein, food.Carbs, food.Fat, food.Type,
 		food.MeasurementUnit, food.MeasurementAmount, food.Public, food.CreatedAt,
+		food.YieldWeight, food.Servings, food.Density, food.Brand,
+		food.SourceFamilyID, food.SourceVersion,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("inserting new food version: %w", err)
+		return fmt.Errorf("inserting food: %w", err)
 	}
-
-	stmt, err := tx.Prepare("INSERT INTO food_nutrients (food_id, name, amount, unit) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		return nil, fmt.Errorf("preparing nutrients stmt: %w", err)
+	if err := s.indexFood(ctx, tx, food); err != nil {
+		return err
 	}
-	defer stmt.Close()
 
-	for _, n := range food.Nutrients {
-		if _, err := stmt.Exec(food.ID, n.Name, n.Amount, n.Unit); err != nil {
-			return nil, fmt.Errorf("inserting nutrient: %w", err)
+	for _, p := range food.Portions {
+		_, err := tx.ExecContext(ctx, s.bind("INSERT INTO food_portions (id, food_id, name, amount, unit, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+			p.ID, p.FoodID, p.Name, p.Amount, p.Unit, p.CreatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("inserting food portion: %w", err)
 		}
 	}
 
-	// Insert ingredients
-	if len(food.Ingredients) > 0 {
-		istmt, err := tx.Prepare("INSERT INTO recipe_items (recipe_id, ingredient_id, amount) VALUES (?, ?, ?)")
+	if len(food.Ingredients) == 0 {
+		stmt, err := tx.PrepareContext(ctx, s.bind("INSERT INTO food_nutrients (food_id, name, amount, unit) VALUES (?, ?, ?, ?)"))
 		if err != nil {
-			return nil, fmt.Errorf("preparing ingredients stmt: %w", err)
+			return fmt.Errorf("preparing nutrients stmt: %w", err)
 		}
-		defer istmt.Close()
+		defer stmt.Close()
 
-		for _, i := range food.Ingredients {
-			if _, err := istmt.Exec(food.ID, i.IngredientID, i.Amount); err != nil {
-				return nil, fmt.Errorf("inserting ingredient: %w", err)
+		for _, n := range food.Nutrients {
+			if _, err := stmt.ExecContext(ctx, food.ID, n.Name, n.Amount, n.Unit); err != nil {
+				return fmt.Errorf("inserting nutrient: %w", err)
 			}
 		}
+		return nil
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing update: %w", err)
+	istmt, err := tx.PrepareContext(ctx, s.bind("INSERT INTO recipe_items (recipe_id, ingredient_id, family_id, follow_latest, amount, unit) VALUES (?, ?, ?, ?, ?, ?)"))
+	if err != nil {
+		return fmt.Errorf("preparing ingredients stmt: %w", err)
 	}
+	defer istmt.Close()
 
-	return &food, nil
+	for _, i := range food.Ingredients {
+		if _, err := istmt.ExecContext(ctx, food.ID, i.IngredientID, i.FamilyID, i.FollowLatest, i.Amount, i.Unit); err != nil {
+			return fmt.Errorf("inserting ingredient: %w", err)
+		}
+	}
+	return nil
 }
 
-func DeleteFood(id FoodID) error {
-	var familyID FoodFamilyID
-	err := db.QueryRow("SELECT family_id FROM foods WHERE id = ?", id).Scan(&familyID)
+// DeleteFood soft-deletes every version of id's family on behalf of userID,
+// who must have created the food.
+func (s *sqlStore) DeleteFood(ctx context.Context, userID UserID, id FoodID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	f, err := s.getFood(ctx, id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil
-		}
-		return fmt.Errorf("finding food to delete: %w", err)
+		return err
+	}
+	if err := checkFoodAccess(f, userID, EditFood); err != nil {
+		return err
 	}
 
-	_, err = db.Exec("UPDATE foods SET deleted_at = ? WHERE family_id = ?", time.Now(), familyID)
+	_, err = s.db.ExecContext(ctx, s.bind("UPDATE foods SET deleted_at = ? WHERE family_id = ?"), time.Now(), f.FamilyID)
 	if err != nil {
 		return fmt.Errorf("deleting food family: %w", err)
 	}
 
 	return nil
 }
+
+// latestVersion returns the current version of the family id belongs to, or
+// id itself if the family has no live current version.
+func (s *sqlStore) latestVersion(ctx context.Context, id FoodID) (FoodID, error) {
+	query := `
+		SELECT cur.id
+		FROM foods f
+		JOIN foods cur ON cur.family_id = f.family_id
+		WHERE f.id = ? AND cur.is_current = true AND cur.deleted_at IS NULL
+	`
+	var latest FoodID
+	err := s.db.QueryRowContext(ctx, s.bind(query), id).Scan(&latest)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return id, nil
+		}
+		return id, fmt.Errorf("finding latest version: %w", err)
+	}
+	return latest, nil
+}
@@ -1,15 +1,14 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
-func TestFoodLifecycle(t *testing.T) {
-	if db == nil {
-		t.Skip("Database not initialized")
-	}
-
-	user := createTestUser(t)
+func testFoodLifecycle(t *testing.T, s Store) {
+	user := createTestUser(t, s)
 
 	// 1. Create Food with Nutrients
 	food := Food{
@@ -27,7 +26,7 @@ func TestFoodLifecycle(t *testing.T) {
 		},
 	}
 
-	created, err := CreateFood(food)
+	created, err := s.CreateFood(t.Context(), food)
 	if err != nil {
 		t.Fatalf("CreateFood failed: %v", err)
 	}
@@ -45,7 +44,7 @@ func TestFoodLifecycle(t *testing.T) {
 	}
 
 	// 2. Get Food
-	fetched, err := GetFood(created.ID)
+	fetched, err := s.GetFood(t.Context(), created.ID)
 	if err != nil {
 		t.Fatalf("GetFood failed: %v", err)
 	}
@@ -68,10 +67,11 @@ func TestFoodLifecycle(t *testing.T) {
 
 	// 3. List Foods
 	// Should create another food to test listing multiple? Or just one is fine.
-	list, err := GetFoods(user.ID)
+	listPage, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods failed: %v", err)
 	}
+	list := listPage.Items
 	// Might be > 1 if createTestIngredient used same user, but createTestUser makes unique user.
 	if len(list) != 1 {
 		t.Errorf("Expected 1 food, got %d", len(list))
@@ -91,7 +91,7 @@ func TestFoodLifecycle(t *testing.T) {
 	created.Nutrients = []FoodNutrient{
 		{Name: "Potassium", Amount: 450, Unit: "mg"},
 	}
-	updated, err := UpdateFood(created.ID, *created)
+	updated, err := s.UpdateFood(t.Context(), created.ID, *created)
 	if err != nil {
 		t.Fatalf("UpdateFood failed: %v", err)
 	}
@@ -109,7 +109,7 @@ func TestFoodLifecycle(t *testing.T) {
 	}
 
 	// Verify old version is not current
-	old, err := GetFood(created.ID)
+	old, err := s.GetFood(t.Context(), created.ID)
 	if err != nil {
 		t.Fatalf("GetFood (old) failed: %v", err)
 	}
@@ -118,10 +118,11 @@ func TestFoodLifecycle(t *testing.T) {
 	}
 
 	// Verify GetFoods only shows current
-	listV2, err := GetFoods(user.ID)
+	listV2Page, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods (v2) failed: %v", err)
 	}
+	listV2 := listV2Page.Items
 	if len(listV2) != 1 {
 		t.Errorf("Expected 1 food, got %d", len(listV2))
 	}
@@ -130,7 +131,7 @@ func TestFoodLifecycle(t *testing.T) {
 	}
 
 	// 5. Get Versions
-	versions, err := GetFoodVersions(updated.ID)
+	versions, err := s.GetFoodVersions(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("GetFoodVersions failed: %v", err)
 	}
@@ -142,20 +143,21 @@ func TestFoodLifecycle(t *testing.T) {
 	}
 
 	// 6. Delete Food
-	err = DeleteFood(updated.ID)
+	err = s.DeleteFood(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("DeleteFood failed: %v", err)
 	}
 
-	listAfterDelete, err := GetFoods(user.ID)
+	listAfterDeletePage, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods (after delete) failed: %v", err)
 	}
+	listAfterDelete := listAfterDeletePage.Items
 	if len(listAfterDelete) != 0 {
 		t.Errorf("Expected 0 foods, got %d", len(listAfterDelete))
 	}
 
-	versionsAfterDelete, err := GetFoodVersions(updated.ID)
+	versionsAfterDelete, err := s.GetFoodVersions(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("GetFoodVersions (after delete) failed: %v", err)
 	}
@@ -163,3 +165,17 @@ func TestFoodLifecycle(t *testing.T) {
 		t.Errorf("Expected 0 versions after delete")
 	}
 }
+
+func testCancelledContext(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, err := s.GetFoods(ctx, user.ID, AllFoods, PageOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetFoods, got %v", err)
+	}
+	if _, err := s.GetStats(ctx, user.ID, mustPeriod(t, "day", time.Now())); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetStats, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ForkFood copies the food version id into a new private family owned by
+// userID, with its nutrients, ingredients and portions. The copy records the
+// family and version it came from. userID must be able to see the food, and
+// deleted foods cannot be forked.
+func (s *sqlStore) ForkFood(ctx context.Context, userID UserID, id FoodID) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	source, err := s.getFood(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFoodAccess(source, userID, ViewFood); err != nil {
+		return nil, err
+	}
+	if source.DeletedAt != nil {
+		return nil, ErrFoodNotFound
+	}
+
+	newID, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generating id: %w", err)
+	}
+	fork := *source
+	fork.ID = FoodID(newID)
+	fork.FamilyID = FoodFamilyID(newID)
+	fork.CreatorID = userID
+	fork.Public = false
+	fork.CreatedAt = time.Now()
+	fork.SourceFamilyID = &source.FamilyID
+	fork.SourceVersion = &source.Version
+	for i := range fork.Portions {
+		fork.Portions[i].CreatedAt = time.Time{}
+	}
+	return s.CreateFood(ctx, fork)
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func testFoodFork(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	other := createTestUser(t, s)
+
+	// The other user's public recipe of 200g of flour, with a portion
+	flour := createSearchFood(t, s, other, "Flour", "", true)
+	recipe, err := s.CreateFood(t.Context(), Food{
+		CreatorID:         other.ID,
+		Name:              "Bread",
+		Public:            true,
+		MeasurementUnit:   "g",
+		MeasurementAmount: 200,
+		Ingredients:       []RecipeItems{{IngredientID: flour.ID, Amount: 200}},
+		Portions:          []FoodPortion{{Name: "1 slice", Amount: 25, Unit: "g"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (recipe) failed: %v", err)
+	}
+	secret := createSearchFood(t, s, other, "Secret", "", false)
+
+	// 1. Private foods of other users cannot be forked
+	if _, err := s.ForkFood(t.Context(), user.ID, secret.ID); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound forking a private food, got %v", err)
+	}
+
+	// 2. Forking copies the recipe into a new private family owned by the caller
+	fork, err := s.ForkFood(t.Context(), user.ID, recipe.ID)
+	if err != nil {
+		t.Fatalf("ForkFood failed: %v", err)
+	}
+	if fork.FamilyID == recipe.FamilyID || fork.Version != 1 || !fork.IsCurrent {
+		t.Errorf("Expected a new family at version 1, got family %v version %d", fork.FamilyID, fork.Version)
+	}
+	if fork.CreatorID != user.ID || fork.Public {
+		t.Errorf("Expected a private fork owned by the caller, got creator %v public %v", fork.CreatorID, fork.Public)
+	}
+	if fork.SourceFamilyID == nil || *fork.SourceFamilyID != recipe.FamilyID || fork.SourceVersion == nil || *fork.SourceVersion != 1 {
+		t.Errorf("Expected provenance %v v1, got %v v%v", recipe.FamilyID, fork.SourceFamilyID, fork.SourceVersion)
+	}
+
+	got, err := s.GetFood(t.Context(), fork.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if got.Name != "Bread" || got.Calories != 200 || len(got.Ingredients) != 1 || got.Ingredients[0].IngredientID != flour.ID {
+		t.Errorf("Expected Bread made of flour with 200kcal, got %+v", got)
+	}
+	if len(got.Portions) != 1 || got.Portions[0].Name != "1 slice" || got.Portions[0].ID == recipe.Portions[0].ID {
+		t.Errorf("Expected a copy of the slice portion, got %+v", got.Portions)
+	}
+	if got.SourceFamilyID == nil || *got.SourceFamilyID != recipe.FamilyID {
+		t.Errorf("Expected provenance to be stored, got %v", got.SourceFamilyID)
+	}
+
+	// 3. The fork is the caller's to change, and keeps its provenance
+	got.Name = "My Bread"
+	got.CreatorID = user.ID
+	got.Portions = nil
+	updated, err := s.UpdateFood(t.Context(), fork.ID, *got)
+	if err != nil {
+		t.Fatalf("UpdateFood (fork) failed: %v", err)
+	}
+	if updated.SourceFamilyID == nil || *updated.SourceFamilyID != recipe.FamilyID || *updated.SourceVersion != 1 {
+		t.Errorf("Expected the new version to keep its provenance, got %v v%v", updated.SourceFamilyID, updated.SourceVersion)
+	}
+	original, err := s.GetFood(t.Context(), recipe.ID)
+	if err != nil {
+		t.Fatalf("GetFood (original) failed: %v", err)
+	}
+	if original.Name != "Bread" || !original.IsCurrent || original.SourceFamilyID != nil {
+		t.Errorf("Expected the original untouched, got %+v", original)
+	}
+
+	// 4. Deleted foods cannot be forked
+	if err := s.DeleteFood(t.Context(), other.ID, recipe.ID); err != nil {
+		t.Fatalf("DeleteFood failed: %v", err)
+	}
+	if _, err := s.ForkFood(t.Context(), user.ID, recipe.ID); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound forking a deleted food, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"azule.info/calorize/internal/units"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidGoal is returned when a goal has no valid effective date, no
+// targets, or a target without sensible bounds.
+var ErrInvalidGoal = errors.New("invalid goal")
+
+// macroCalories is the energy per gram of each macro, used to judge targets
+// given as a percentage of calories.
+var macroCalories = map[string]float64{"protein": 4, "carbs": 4, "fat": 9}
+
+// GetGoals lists userID's goals, the most recently effective first.
+func (s *sqlStore) GetGoals(ctx context.Context, userID UserID) ([]Goal, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getGoals(ctx, userID)
+}
+
+func (s *sqlStore) getGoals(ctx context.Context, userID UserID) ([]Goal, error) {
+	query := `
+		SELECT id, user_id, effective_from, created_at
+		FROM goals
+		WHERE user_id = ?
+		ORDER BY effective_from DESC
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	byID := make(map[GoalID]int)
+	for rows.Next() {
+		var g Goal
+		if err := rows.Scan(&g.ID, &g.UserID, &g.EffectiveFrom, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning goal: %w", err)
+		}
+		byID[g.ID] = len(goals)
+		goals = append(goals, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing goals: %w", err)
+	}
+	rows.Close()
+
+	query = `
+		SELECT gt.goal_id, gt.nutrient, gt.unit, gt.min_amount, gt.max_amount
+		FROM goal_targets gt
+		JOIN goals g ON g.id = gt.goal_id
+		WHERE g.user_id = ?
+		ORDER BY gt.nutrient, gt.unit
+	`
+	rows, err = s.db.QueryContext(ctx, s.bind(query), userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing goal targets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id GoalID
+		var t GoalTarget
+		if err := rows.Scan(&id, &t.Nutrient, &t.Unit, &t.Min, &t.Max); err != nil {
+			return nil, fmt.Errorf("scanning goal target: %w", err)
+		}
+		if i, ok := byID[id]; ok {
+			goals[i].Targets = append(goals[i].Targets, t)
+		}
+	}
+	return goals, rows.Err()
+}
+
+// SetGoal saves goal as the user's targets from goal.EffectiveFrom onwards,
+// replacing any goal they already had taking effect on that day.
+func (s *sqlStore) SetGoal(ctx context.Context, goal Goal) (*Goal, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := goal.check(); err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generating id: %w", err)
+	}
+	goal.ID = GoalID(id)
+	if goal.CreatedAt.IsZero() {
+		goal.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	replaced := "SELECT id FROM goals WHERE user_id = ? AND effective_from = ?"
+	_, err = tx.ExecContext(ctx, s.bind("DELETE FROM goal_targets WHERE goal_id IN ("+replaced+")"), goal.UserID, goal.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("replacing goal targets: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, s.bind("DELETE FROM goals WHERE user_id = ? AND effective_from = ?"), goal.UserID, goal.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("replacing goal: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, s.bind("INSERT INTO goals (id, user_id, effective_from, created_at) VALUES (?, ?, ?, ?)"),
+		goal.ID, goal.UserID, goal.EffectiveFrom, goal.CreatedAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("inserting goal: %w", err)
+	}
+	for _, t := range goal.Targets {
+		_, err = tx.ExecContext(ctx, s.bind("INSERT INTO goal_targets (goal_id, nutrient, unit, min_amount, max_amount) VALUES (?, ?, ?, ?, ?)"),
+			goal.ID, t.Nutrient, t.Unit, t.Min, t.Max)
+		if err != nil {
+			return nil, fmt.Errorf("inserting goal target: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing goal: %w", err)
+	}
+	return &goal, nil
+}
+
+// DeleteGoal removes one of userID's goals, so the previous goal applies
+// again from its day.
+func (s *sqlStore) DeleteGoal(ctx context.Context, userID UserID, id GoalID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, s.bind("DELETE FROM goal_targets WHERE goal_id IN (SELECT id FROM goals WHERE id = ? AND user_id = ?)"), id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting goal targets: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, s.bind("DELETE FROM goals WHERE id = ? AND user_id = ?"), id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting goal: %w", err)
+	}
+	return tx.Commit()
+}
+
+// check validates the goal and normalizes its targets: macro names are
+// lowercased, calories are in kcal and macros default to grams.
+func (g *Goal) check() error {
+	if _, err := time.Parse("2006-01-02", g.EffectiveFrom); err != nil {
+		return fmt.Errorf("%w: effective_from must be YYYY-MM-DD", ErrInvalidGoal)
+	}
+	if len(g.Targets) == 0 {
+		return fmt.Errorf("%w: no targets", ErrInvalidGoal)
+	}
+
+	for i := range g.Targets {
+		t := &g.Targets[i]
+		t.Nutrient = strings.TrimSpace(t.Nutrient)
+		t.Unit = strings.TrimSpace(t.Unit)
+		if t.Nutrient == "" {
+			return fmt.Errorf("%w: target without a nutrient", ErrInvalidGoal)
+		}
+
+		lower := strings.ToLower(t.Nutrient)
+		_, macro := macroCalories[lower]
+		switch {
+		case lower == "calories":
+			if t.Unit != "" && t.Unit != "kcal" {
+				return fmt.Errorf("%w: calories are in kcal", ErrInvalidGoal)
+			}
+			t.Nutrient, t.Unit = lower, "kcal"
+		case macro:
+			switch {
+			case t.Unit == "" || units.Same(t.Unit, "g"):
+				t.Unit = "g"
+			case t.Unit != "%":
+				return fmt.Errorf("%w: %s is in g or %%", ErrInvalidGoal, lower)
+			}
+			t.Nutrient = lower
+		case t.Unit == "" || t.Unit == "%":
+			return fmt.Errorf("%w: %s needs a unit", ErrInvalidGoal, t.Nutrient)
+		}
+
+		if t.Min == nil && t.Max == nil {
+			return fmt.Errorf("%w: %s needs a min or max", ErrInvalidGoal, t.Nutrient)
+		}
+		if (t.Min != nil && *t.Min < 0) || (t.Max != nil && *t.Max < 0) {
+			return fmt.Errorf("%w: %s bounds must not be negative", ErrInvalidGoal, t.Nutrient)
+		}
+		if t.Min != nil && t.Max != nil && *t.Min > *t.Max {
+			return fmt.Errorf("%w: %s min is above its max", ErrInvalidGoal, t.Nutrient)
+		}
+		if t.Unit == "%" && t.Max != nil && *t.Max > 100 {
+			return fmt.Errorf("%w: %s cannot exceed 100%%", ErrInvalidGoal, t.Nutrient)
+		}
+
+		for _, prev := range g.Targets[:i] {
+			if strings.EqualFold(prev.Nutrient, t.Nutrient) && prev.Unit == t.Unit {
+				return fmt.Errorf("%w: %s is targeted twice", ErrInvalidGoal, t.Nutrient)
+			}
+		}
+	}
+	return nil
+}
+
+// activeGoal returns the goal in effect on day (YYYY-MM-DD), given goals
+// ordered most recently effective first.
+func activeGoal(goals []Goal, day string) *Goal {
+	for i := range goals {
+		if goals[i].EffectiveFrom <= day {
+			return &goals[i]
+		}
+	}
+	return nil
+}
+
+// GoalProgress compares a period's logged nutrition with the goals in effect
+// on its days up to today. DaysTracked counts the days with logs and a goal,
+// DaysMet those that met every target, and Adherence is DaysMet as a
+// percentage of DaysTracked.
+type GoalProgress struct {
+	Targets     []TargetProgress `json:"targets"`
+	DaysTracked int              `json:"days_tracked"`
+	DaysMet     int              `json:"days_met"`
+	Adherence   float64          `json:"adherence"`
+}
+
+// TargetProgress is one target over a period. Min and Max are the daily
+// bounds summed over the days so far with this target, or averaged for
+// percentage targets, so a period still under way is judged on its days to
+// date rather than on days yet to come. Remaining is what can still be
+// logged before reaching Max (or Min, without a Max), Under is the shortfall
+// from Min and Over the excess beyond Max.
+type TargetProgress struct {
+	Nutrient  string   `json:"nutrient"`
+	Unit      string   `json:"unit"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Actual    float64  `json:"actual"`
+	Remaining float64  `json:"remaining"`
+	Under     float64  `json:"under"`
+	Over      float64  `json:"over"`
+	Met       bool     `json:"met"`
+}
+
+// goalProgress judges total, the stats for a whole period, and days, its
+// daily stats, against goals. Days after today (YYYY-MM-DD, in the user's
+// time zone) are left out.
+func goalProgress(goals []Goal, total RangeStats, days []RangeStats, today string) *GoalProgress {
+	type bounds struct {
+		target   GoalTarget
+		min, max float64
+		hasMin   bool
+		hasMax   bool
+		days     int
+	}
+	var order []string
+	summed := make(map[string]*bounds)
+
+	progress := &GoalProgress{Targets: []TargetProgress{}}
+	for _, day := range days {
+		if day.Date > today {
+			break
+		}
+		goal := activeGoal(goals, day.Date)
+		if goal == nil {
+			continue
+		}
+		met := true
+		for _, t := range goal.Targets {
+			if !newTargetProgress(t, t.Min, t.Max, day).Met {
+				met = false
+			}
+
+			key := strings.ToLower(t.Nutrient) + "\x00" + t.Unit
+			b, ok := summed[key]
+			if !ok {
+				b = &bounds{target: t}
+				summed[key] = b
+				order = append(order, key)
+			}
+			b.days++
+			if t.Min != nil {
+				b.min += *t.Min
+				b.hasMin = true
+			}
+			if t.Max != nil {
+				b.max += *t.Max
+				b.hasMax = true
+			}
+		}
+		if len(day.Meals) > 0 {
+			progress.DaysTracked++
+			if met {
+				progress.DaysMet++
+			}
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	for _, key := range order {
+		b := summed[key]
+		if b.target.Unit == "%" {
+			b.min /= float64(b.days)
+			b.max /= float64(b.days)
+		}
+		var lo, hi *float64
+		if b.hasMin {
+			lo = &b.min
+		}
+		if b.hasMax {
+			hi = &b.max
+		}
+		progress.Targets = append(progress.Targets, newTargetProgress(b.target, lo, hi, total))
+	}
+	if progress.DaysTracked > 0 {
+		progress.Adherence = float64(progress.DaysMet) / float64(progress.DaysTracked) * 100
+	}
+	return progress
+}
+
+func newTargetProgress(t GoalTarget, lo, hi *float64, stats RangeStats) TargetProgress {
+	p := TargetProgress{Nutrient: t.Nutrient, Unit: t.Unit, Min: lo, Max: hi, Actual: targetActual(t, stats), Met: true}
+	if lo != nil && p.Actual < *lo {
+		p.Under = *lo - p.Actual
+		p.Met = false
+	}
+	if hi != nil {
+		if p.Actual > *hi {
+			p.Over = p.Actual - *hi
+			p.Met = false
+		} else {
+			p.Remaining = *hi - p.Actual
+		}
+	} else if lo != nil {
+		p.Remaining = p.Under
+	}
+	return p
+}
+
+// targetActual reads the logged amount a target applies to out of stats.
+func targetActual(t GoalTarget, stats RangeStats) float64 {
+	var grams float64
+	switch t.Nutrient {
+	case "calories":
+		return stats.Calories
+	case "protein":
+		grams = stats.Protein
+	case "carbs":
+		grams = stats.Carbs
+	case "fat":
+		grams = stats.Fat
+	default:
+		var total float64
+		for _, n := range stats.Nutrients {
+			if !strings.EqualFold(n.Name, t.Nutrient) {
+				continue
+			}
+			if amount, err := units.Convert(n.Amount, n.Unit, t.Unit, 0); err == nil {
+				total += amount
+			}
+		}
+		return total
+	}
+	if t.Unit != "%" {
+		return grams
+	}
+	if stats.Calories <= 0 {
+		return 0
+	}
+	return grams * macroCalories[t.Nutrient] / stats.Calories * 100
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func testGoals(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	amount := func(v float64) *float64 { return &v }
+
+	// 100g: 100kcal, 10p, 10c, 2f and 1mg of iron
+	food := createTestIngredient(t, s, user, "Test Food")
+	food.Nutrients = []FoodNutrient{{Name: "Iron", Amount: 1, Unit: "mg"}}
+	food, err := s.UpdateFood(t.Context(), food.ID, *food)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+
+	// 1. Invalid goals are rejected
+	invalid := []Goal{
+		{UserID: user.ID, EffectiveFrom: "March 1st", Targets: []GoalTarget{{Nutrient: "calories", Max: amount(2000)}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01"},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "calories"}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "calories", Unit: "g", Max: amount(2000)}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "protein", Min: amount(200), Max: amount(100)}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "fat", Unit: "%", Max: amount(120)}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "Iron", Min: amount(8)}}},
+		{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{{Nutrient: "Protein", Min: amount(50)}, {Nutrient: "protein", Unit: "g", Max: amount(150)}}},
+	}
+	for _, g := range invalid {
+		if _, err := s.SetGoal(t.Context(), g); !errors.Is(err, ErrInvalidGoal) {
+			t.Errorf("Expected ErrInvalidGoal for %+v, got %v", g, err)
+		}
+	}
+
+	// 2. Goal A from March 1st, goal B (calories only) from March 3rd
+	goalA, err := s.SetGoal(t.Context(), Goal{UserID: user.ID, EffectiveFrom: "2024-03-01", Targets: []GoalTarget{
+		{Nutrient: "Calories", Max: amount(300)},
+		{Nutrient: "protein", Min: amount(25)},
+		{Nutrient: "fat", Unit: "%", Max: amount(20)},
+		{Nutrient: "Iron", Unit: "mg", Min: amount(2)},
+	}})
+	if err != nil {
+		t.Fatalf("SetGoal failed: %v", err)
+	}
+	if goalA.Targets[0].Nutrient != "calories" || goalA.Targets[0].Unit != "kcal" || goalA.Targets[1].Unit != "g" {
+		t.Errorf("Targets not normalized: %+v", goalA.Targets)
+	}
+	goalB, err := s.SetGoal(t.Context(), Goal{UserID: user.ID, EffectiveFrom: "2024-03-03", Targets: []GoalTarget{
+		{Nutrient: "calories", Max: amount(100)},
+	}})
+	if err != nil {
+		t.Fatalf("SetGoal failed: %v", err)
+	}
+	// Setting a goal for the same day replaces it
+	goalB, err = s.SetGoal(t.Context(), Goal{UserID: user.ID, EffectiveFrom: "2024-03-03", Targets: []GoalTarget{
+		{Nutrient: "calories", Max: amount(150)},
+	}})
+	if err != nil {
+		t.Fatalf("SetGoal (replace) failed: %v", err)
+	}
+	goals, err := s.GetGoals(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("GetGoals failed: %v", err)
+	}
+	if len(goals) != 2 || goals[0].ID != goalB.ID || goals[1].ID != goalA.ID {
+		t.Fatalf("Expected goals B then A, got %+v", goals)
+	}
+	if len(goals[1].Targets) != 4 || *goals[0].Targets[0].Max != 150 {
+		t.Errorf("Goal targets not stored: %+v", goals)
+	}
+
+	// 3. Log 200g on the 1st (too little protein), 300g on the 2nd (all met)
+	// and 200g on the 3rd (over goal B's calories)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	createTestLogEntry(t, s, user, food, 200, day(1))
+	createTestLogEntry(t, s, user, food, 300, day(2))
+	createTestLogEntry(t, s, user, food, 200, day(3))
+
+	// 4. A single day
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", day(2)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Goal == nil {
+		t.Fatalf("Expected goal progress")
+	}
+	if stats.Goal.DaysTracked != 1 || stats.Goal.DaysMet != 1 || stats.Goal.Adherence != 100 {
+		t.Errorf("Expected the day to be met, got %+v", stats.Goal)
+	}
+	calories := findTarget(t, stats.Goal, "calories")
+	if calories.Nutrient != "calories" || calories.Actual != 300 || calories.Remaining != 0 || calories.Over != 0 || !calories.Met {
+		t.Errorf("Unexpected calorie progress: %+v", calories)
+	}
+
+	// 5. A range sums daily calorie and protein bounds and averages percentages
+	p, err := RangePeriod(day(1), day(4))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	stats, err = s.GetStats(t.Context(), user.ID, p)
+	if err != nil {
+		t.Fatalf("GetStats (range) failed: %v", err)
+	}
+	g := stats.Goal
+	if g == nil {
+		t.Fatalf("Expected goal progress for the range")
+	}
+	if g.DaysTracked != 3 || g.DaysMet != 1 || math.Abs(g.Adherence-100.0/3) > 1e-9 {
+		t.Errorf("Expected 1 of 3 days met, got %+v", g)
+	}
+	want := map[string]TargetProgress{
+		"calories": {Max: amount(900), Actual: 700, Remaining: 200, Met: true},
+		"protein":  {Min: amount(50), Actual: 70, Met: true},
+		"fat":      {Max: amount(20), Actual: 18, Remaining: 2, Met: true},
+		"Iron":     {Min: amount(4), Actual: 7, Met: true},
+	}
+	if len(g.Targets) != len(want) {
+		t.Fatalf("Expected %d targets, got %+v", len(want), g.Targets)
+	}
+	for _, got := range g.Targets {
+		w := want[got.Nutrient]
+		if (w.Min != nil && (got.Min == nil || *got.Min != *w.Min)) || (w.Max != nil && (got.Max == nil || math.Abs(*got.Max-*w.Max) > 1e-9)) {
+			t.Errorf("%s: unexpected bounds %+v", got.Nutrient, got)
+		}
+		if math.Abs(got.Actual-w.Actual) > 1e-9 || math.Abs(got.Remaining-w.Remaining) > 1e-9 || got.Met != w.Met {
+			t.Errorf("%s: expected %+v, got %+v", got.Nutrient, w, got)
+		}
+	}
+
+	// 6. Over a maximum and under a minimum
+	stats, err = s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", day(3)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if c := findTarget(t, stats.Goal, "calories"); c.Over != 50 || c.Met || stats.Goal.Adherence != 0 {
+		t.Errorf("Expected 50 kcal over, got %+v", stats.Goal)
+	}
+	stats, err = s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", day(1)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if p := findTarget(t, stats.Goal, "protein"); p.Under != 5 || p.Remaining != 5 || p.Met {
+		t.Errorf("Expected protein 5g under, got %+v", p)
+	}
+
+	// 7. Days before the first goal have no progress
+	stats, err = s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", day(1).AddDate(0, 0, -1)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Goal != nil {
+		t.Errorf("Expected no goal progress before the first goal, got %+v", stats.Goal)
+	}
+
+	// 8. A period still under way only counts the bounds of its days so far
+	now := time.Now()
+	p, err = RangePeriod(now.AddDate(0, 0, -1), now.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	stats, err = s.GetStats(t.Context(), user.ID, p)
+	if err != nil {
+		t.Fatalf("GetStats (current) failed: %v", err)
+	}
+	if c := findTarget(t, stats.Goal, "calories"); c.Max == nil || *c.Max != 300 || c.Remaining != 300 {
+		t.Errorf("Expected two days of goal B's calories, got %+v", c)
+	}
+
+	// 9. Only the owner can delete a goal; deleting B brings back A
+	other := createTestUser(t, s)
+	if err := s.DeleteGoal(t.Context(), other.ID, goalB.ID); err != nil {
+		t.Fatalf("DeleteGoal (other user) failed: %v", err)
+	}
+	if goals, _ := s.GetGoals(t.Context(), user.ID); len(goals) != 2 {
+		t.Errorf("Goal deleted by another user")
+	}
+	if err := s.DeleteGoal(t.Context(), user.ID, goalB.ID); err != nil {
+		t.Fatalf("DeleteGoal failed: %v", err)
+	}
+	stats, err = s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", day(3)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if len(stats.Goal.Targets) != 4 {
+		t.Errorf("Expected goal A on the 3rd after deleting B, got %+v", stats.Goal)
+	}
+}
+
+func findTarget(t *testing.T, g *GoalProgress, nutrient string) TargetProgress {
+	t.Helper()
+	for _, target := range g.Targets {
+		if target.Nutrient == nutrient {
+			return target
+		}
+	}
+	t.Fatalf("No %s target in %+v", nutrient, g.Targets)
+	return TargetProgress{}
+}
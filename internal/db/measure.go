@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"azule.info/calorize/internal/units"
+)
+
+// ErrInvalidDensity is returned when a food is written with a negative density.
+var ErrInvalidDensity = errors.New("density must not be negative")
+
+// measure converts amount in unit into the food's measurement unit. An empty
+// unit is already in the measurement unit. When a recipe records its yield,
+// servings and weights are taken as shares of that yield.
+func (f *Food) measure(amount float64, unit string) (float64, error) {
+	if unit == "" {
+		return amount, nil
+	}
+	if f.Servings > 0 && units.Same(unit, "serving") {
+		return f.AmountForServings(amount)
+	}
+	if f.YieldWeight > 0 {
+		if grams, err := units.Convert(amount, unit, "g", f.Density); err == nil {
+			return f.AmountForCookedGrams(grams)
+		}
+	}
+
+	converted, err := units.Convert(amount, unit, f.MeasurementUnit, f.Density)
+	if err != nil {
+		return 0, fmt.Errorf("measuring %s: %w", f.Name, err)
+	}
+	return converted, nil
+}
+
+// amountOf is the inverse of servingsOf: the amount in the food's measurement
+// unit that holds factor times its nutrition.
+func amountOf(f *Food, factor float64) float64 {
+	if f.MeasurementAmount == 0 {
+		return factor
+	}
+	return factor * f.MeasurementAmount
+}
+
+// weightOf returns the weight in grams of amount of f, if it can be known
+// from the measurement unit and density or, for recipes, the yield weight.
+func weightOf(f *Food, amount float64) (float64, bool) {
+	if grams, err := units.Convert(amount, f.MeasurementUnit, "g", f.Density); err == nil {
+		return grams, true
+	}
+	if f.YieldWeight > 0 {
+		return servingsOf(f, amount) * f.YieldWeight, true
+	}
+	return 0, false
+}
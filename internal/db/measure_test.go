@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"azule.info/calorize/internal/units"
+)
+
+func testUnitConversion(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 100g: 100kcal, 10p, 10c, 2f
+	flour := createTestIngredient(t, s, user, "Flour")
+	flour.Density = 0.5
+	flour, err := s.UpdateFood(t.Context(), flour.ID, *flour)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	sugar := createTestIngredient(t, s, user, "Sugar")
+
+	// 1. Log entries carry their own unit and stats convert them
+	for _, e := range []FoodLogEntry{
+		{FoodID: flour.ID, Amount: 1, Unit: "kg"},
+		{FoodID: flour.ID, Amount: 1, Unit: "cup"},
+		{FoodID: sugar.ID, Amount: 50},
+	} {
+		e.UserID = user.ID
+		e.LoggedAt = time.Now()
+		if _, err := s.CreateFoodLogEntry(t.Context(), e); err != nil {
+			t.Fatalf("CreateFoodLogEntry (%v %s) failed: %v", e.Amount, e.Unit, err)
+		}
+	}
+	entriesPage, err := s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(time.Now()), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries failed: %v", err)
+	}
+	entries := entriesPage.Items
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Now()))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	// 1000g + 1 cup at 0.5 g/ml (118.29g) of flour, plus 50g of sugar
+	if math.Abs(stats.Calories-1168.29411825) > 1e-6 {
+		t.Errorf("Expected 1168.294 calories, got %f", stats.Calories)
+	}
+
+	// 2. Incompatible units are rejected when logging
+	_, err = s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: flour.ID, Amount: 1, Unit: "slice", LoggedAt: time.Now()})
+	if !errors.Is(err, units.ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible for slices of flour, got %v", err)
+	}
+	_, err = s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: sugar.ID, Amount: 1, Unit: "cup", LoggedAt: time.Now()})
+	if !errors.Is(err, units.ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible for a cup of sugar without density, got %v", err)
+	}
+
+	// 3. Recipe items are converted too
+	roux, err := s.CreateFood(t.Context(), Food{
+		CreatorID: user.ID,
+		Name:      "Roux",
+		Ingredients: []RecipeItems{
+			{IngredientID: flour.ID, Amount: 2, Unit: "tbsp"},
+			{IngredientID: sugar.ID, Amount: 1, Unit: "oz"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	fetched, err := s.GetFood(t.Context(), roux.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	// 2 tbsp at 0.5 g/ml is 14.787g of flour, plus 28.350g of sugar
+	if math.Abs(fetched.Calories-43.1364) > 1e-3 {
+		t.Errorf("Expected 43.136 calories, got %f", fetched.Calories)
+	}
+	if math.Abs(fetched.RawWeight-43.1364) > 1e-3 {
+		t.Errorf("Expected raw weight 43.136, got %f", fetched.RawWeight)
+	}
+	if len(fetched.Ingredients) != 2 || fetched.Ingredients[0].Unit == "" {
+		t.Errorf("Expected ingredient units to be stored, got %+v", fetched.Ingredients)
+	}
+
+	_, err = s.CreateFood(t.Context(), Food{
+		CreatorID:   user.ID,
+		Name:        "Bad",
+		Ingredients: []RecipeItems{{IngredientID: sugar.ID, Amount: 1, Unit: "cup"}},
+	})
+	if !errors.Is(err, units.ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible for a recipe item, got %v", err)
+	}
+}
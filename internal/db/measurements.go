@@ -0,0 +1,316 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"azule.info/calorize/internal/units"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMeasurementNotFound is returned when a measurement does not exist
+	// for the given user.
+	ErrMeasurementNotFound = errors.New("measurement not found")
+	// ErrInvalidMeasurement is returned when a measurement has an unknown
+	// kind, a unit that does not fit it or a non-positive value.
+	ErrInvalidMeasurement = errors.New("invalid measurement")
+)
+
+// measurementKinds maps each kind of measurement to the dimension of its units
+// and the unit it defaults to. Body fat is a percentage, which is a count unit.
+var measurementKinds = map[string]struct {
+	dim  units.Dimension
+	unit string
+}{
+	"weight":   {units.Mass, "kg"},
+	"body_fat": {units.Count, "%"},
+	"waist":    {units.Length, "cm"},
+	"hips":     {units.Length, "cm"},
+	"chest":    {units.Length, "cm"},
+	"neck":     {units.Length, "cm"},
+	"arm":      {units.Length, "cm"},
+	"thigh":    {units.Length, "cm"},
+}
+
+const (
+	// trendSmoothing is the weight of each day's value in a measurement trend,
+	// an exponentially weighted moving average. Small weights even out day to
+	// day swings such as water weight.
+	trendSmoothing = 0.1
+	// trendWarmupDays is how many days of readings before a period are read
+	// to settle its trend; older readings would add less than 0.2% to it.
+	trendWarmupDays = 60
+)
+
+// MeasurementFilter selects measurements. From is inclusive and To exclusive;
+// a zero bound leaves that side of the range open. An empty Kind matches
+// every kind.
+type MeasurementFilter struct {
+	Kind string
+	From time.Time
+	To   time.Time
+}
+
+// GetMeasurements lists userID's measurements matching filter, oldest first.
+func (s *sqlStore) GetMeasurements(ctx context.Context, userID UserID, filter MeasurementFilter) ([]Measurement, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getMeasurements(ctx, userID, filter)
+}
+
+func (s *sqlStore) getMeasurements(ctx context.Context, userID UserID, filter MeasurementFilter) ([]Measurement, error) {
+	where := "user_id = ?"
+	args := []any{userID}
+	if filter.Kind != "" {
+		where += " AND kind = ?"
+		args = append(args, strings.ToLower(strings.TrimSpace(filter.Kind)))
+	}
+	if !filter.From.IsZero() {
+		where += " AND measured_at >= ?"
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where += " AND measured_at < ?"
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `
+		SELECT id, user_id, kind, value, unit, measured_at, created_at
+		FROM measurements
+		WHERE ` + where + `
+		ORDER BY measured_at, id
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing measurements: %w", err)
+	}
+	defer rows.Close()
+
+	measurements := []Measurement{}
+	for rows.Next() {
+		var m Measurement
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Kind, &m.Value, &m.Unit, &m.MeasuredAt, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning measurement: %w", err)
+		}
+		measurements = append(measurements, m)
+	}
+	return measurements, rows.Err()
+}
+
+func (s *sqlStore) getMeasurement(ctx context.Context, userID UserID, id MeasurementID) (*Measurement, error) {
+	query := `
+		SELECT id, user_id, kind, value, unit, measured_at, created_at
+		FROM measurements
+		WHERE id = ? AND user_id = ?
+	`
+	var m Measurement
+	err := s.db.QueryRowContext(ctx, s.bind(query), id, userID).Scan(&m.ID, &m.UserID, &m.Kind, &m.Value, &m.Unit, &m.MeasuredAt, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting measurement: %w", err)
+	}
+	return &m, nil
+}
+
+// CreateMeasurement records a reading. A zero MeasuredAt defaults to now.
+func (s *sqlStore) CreateMeasurement(ctx context.Context, m Measurement) (*Measurement, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generating id: %w", err)
+	}
+	m.ID = MeasurementID(id)
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	if m.MeasuredAt.IsZero() {
+		m.MeasuredAt = m.CreatedAt
+	}
+
+	query := "INSERT INTO measurements (id, user_id, kind, value, unit, measured_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err = s.db.ExecContext(ctx, s.bind(query), m.ID, m.UserID, m.Kind, m.Value, m.Unit, m.MeasuredAt.UTC(), m.CreatedAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("inserting measurement: %w", err)
+	}
+	return &m, nil
+}
+
+// UpdateMeasurement changes the kind, value, unit and time of one of the
+// user's readings.
+func (s *sqlStore) UpdateMeasurement(ctx context.Context, m Measurement) (*Measurement, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	if m.MeasuredAt.IsZero() {
+		return nil, fmt.Errorf("%w: measured_at is required", ErrInvalidMeasurement)
+	}
+
+	query := "UPDATE measurements SET kind = ?, value = ?, unit = ?, measured_at = ? WHERE id = ? AND user_id = ?"
+	res, err := s.db.ExecContext(ctx, s.bind(query), m.Kind, m.Value, m.Unit, m.MeasuredAt.UTC(), m.ID, m.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("updating measurement: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("updating measurement: %w", err)
+	} else if n == 0 {
+		return nil, ErrMeasurementNotFound
+	}
+	return s.getMeasurement(ctx, m.UserID, m.ID)
+}
+
+// DeleteMeasurement removes one of userID's readings.
+func (s *sqlStore) DeleteMeasurement(ctx context.Context, userID UserID, id MeasurementID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM measurements WHERE id = ? AND user_id = ?"), id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting measurement: %w", err)
+	}
+	return nil
+}
+
+// check validates the measurement and normalizes its kind and unit; the unit
+// defaults to the kind's usual one.
+func (m *Measurement) check() error {
+	m.Kind = strings.ToLower(strings.TrimSpace(m.Kind))
+	kind, ok := measurementKinds[m.Kind]
+	if !ok {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidMeasurement, m.Kind)
+	}
+	if strings.TrimSpace(m.Unit) == "" {
+		m.Unit = kind.unit
+	}
+	unit := units.Parse(m.Unit)
+	if unit.Dimension != kind.dim || (kind.dim == units.Count && unit.Name != kind.unit) {
+		return fmt.Errorf("%w: %s cannot be measured in %s", ErrInvalidMeasurement, m.Kind, m.Unit)
+	}
+	m.Unit = unit.Name
+
+	if m.Value <= 0 || math.IsInf(m.Value, 0) || math.IsNaN(m.Value) {
+		return fmt.Errorf("%w: value must be positive", ErrInvalidMeasurement)
+	}
+	if m.Unit == "%" && m.Value > 100 {
+		return fmt.Errorf("%w: %s cannot exceed 100%%", ErrInvalidMeasurement, m.Kind)
+	}
+	return nil
+}
+
+// MeasurementTrend is one kind of measurement by day, in the unit of its
+// latest reading.
+type MeasurementTrend struct {
+	Kind string           `json:"kind"`
+	Unit string           `json:"unit"`
+	Days []MeasurementDay `json:"days"`
+}
+
+// MeasurementDay is the average of a day's readings and the smoothed trend
+// up to and including that day.
+type MeasurementDay struct {
+	Date     string  `json:"date"` // YYYY-MM-DD
+	Value    float64 `json:"value"`
+	Readings int     `json:"readings"`
+	Trend    float64 `json:"trend"`
+}
+
+// GetMeasurementTrends groups userID's readings of kind, or of every kind if
+// it is empty, into the local days of period and smooths them into a trend.
+// Days are taken in the location of period, and readings from the days before
+// it settle the trend so it does not restart at the period's first day.
+func (s *sqlStore) GetMeasurementTrends(ctx context.Context, userID UserID, kind string, period Period) ([]MeasurementTrend, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getMeasurementTrends(ctx, userID, kind, period)
+}
+
+func (s *sqlStore) getMeasurementTrends(ctx context.Context, userID UserID, kind string, period Period) ([]MeasurementTrend, error) {
+	readings, err := s.getMeasurements(ctx, userID, MeasurementFilter{
+		Kind: kind,
+		From: period.Start.AddDate(0, 0, -trendWarmupDays),
+		To:   period.End,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byKind := make(map[string][]Measurement)
+	for _, m := range readings {
+		byKind[m.Kind] = append(byKind[m.Kind], m)
+	}
+	trends := []MeasurementTrend{}
+	for kind, readings := range byKind {
+		trend := measurementTrend(kind, readings, period)
+		if len(trend.Days) > 0 {
+			trends = append(trends, trend)
+		}
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].Kind < trends[j].Kind })
+	return trends, nil
+}
+
+// measurementTrend averages readings, which are of one kind and oldest first,
+// per local day and runs an exponentially weighted moving average over the
+// days. Days without readings still count towards the smoothing, so a reading
+// after a gap moves the trend further. Only the days within period are
+// returned.
+func measurementTrend(kind string, readings []Measurement, period Period) MeasurementTrend {
+	loc := period.Start.Location()
+	unit := readings[len(readings)-1].Unit
+	first := period.Start.Format("2006-01-02")
+
+	var days []MeasurementDay
+	var totals []float64
+	for _, m := range readings {
+		value, err := units.Convert(m.Value, m.Unit, unit, 0)
+		if err != nil {
+			continue
+		}
+		date := m.MeasuredAt.In(loc).Format("2006-01-02")
+		if n := len(days); n > 0 && days[n-1].Date == date {
+			days[n-1].Readings++
+			totals[n-1] += value
+			continue
+		}
+		days = append(days, MeasurementDay{Date: date, Readings: 1})
+		totals = append(totals, value)
+	}
+
+	trend := MeasurementTrend{Kind: kind, Unit: unit, Days: []MeasurementDay{}}
+	var prev time.Time
+	for i := range days {
+		d := &days[i]
+		d.Value = totals[i] / float64(d.Readings)
+		date, _ := time.Parse("2006-01-02", d.Date)
+		if i == 0 {
+			d.Trend = d.Value
+		} else {
+			gap := math.Round(date.Sub(prev).Hours() / 24)
+			weight := 1 - math.Pow(1-trendSmoothing, gap)
+			d.Trend = days[i-1].Trend + weight*(d.Value-days[i-1].Trend)
+		}
+		prev = date
+		if d.Date >= first {
+			trend.Days = append(trend.Days, *d)
+		}
+	}
+	return trend
+}
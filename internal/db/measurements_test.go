@@ -0,0 +1,140 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func testMeasurements(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	other := createTestUser(t, s)
+
+	// 1. Invalid measurements are rejected
+	invalid := []Measurement{
+		{UserID: user.ID, Kind: "mood", Value: 5},
+		{UserID: user.ID, Kind: "weight", Value: 0},
+		{UserID: user.ID, Kind: "weight", Value: 80, Unit: "cm"},
+		{UserID: user.ID, Kind: "waist", Value: 80, Unit: "kg"},
+		{UserID: user.ID, Kind: "body_fat", Value: 20, Unit: "g"},
+		{UserID: user.ID, Kind: "body_fat", Value: 120},
+	}
+	for _, m := range invalid {
+		if _, err := s.CreateMeasurement(t.Context(), m); !errors.Is(err, ErrInvalidMeasurement) {
+			t.Errorf("Expected ErrInvalidMeasurement for %+v, got %v", m, err)
+		}
+	}
+
+	// 2. Units default to the kind's usual unit and are normalized
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	at := func(d, h int) time.Time { return time.Date(2024, 3, d, h, 0, 0, 0, berlin) }
+	create := func(m Measurement) *Measurement {
+		t.Helper()
+		created, err := s.CreateMeasurement(t.Context(), m)
+		if err != nil {
+			t.Fatalf("CreateMeasurement failed: %v", err)
+		}
+		return created
+	}
+	first := create(Measurement{UserID: user.ID, Kind: "Weight", Value: 80, MeasuredAt: at(1, 8)})
+	if first.Kind != "weight" || first.Unit != "kg" {
+		t.Errorf("Expected weight in kg, got %s in %s", first.Kind, first.Unit)
+	}
+	waist := create(Measurement{UserID: user.ID, Kind: "waist", Value: 34, Unit: "inches", MeasuredAt: at(1, 8)})
+	if waist.Unit != "in" {
+		t.Errorf("Expected unit in, got %s", waist.Unit)
+	}
+
+	// 3. Two readings on the 2nd (one just after local midnight, which is
+	// the 1st in UTC) and one on the 5th, the last in grams
+	create(Measurement{UserID: user.ID, Kind: "weight", Value: 81, MeasuredAt: at(2, 0).Add(30 * time.Minute)})
+	create(Measurement{UserID: user.ID, Kind: "weight", Value: 79, MeasuredAt: at(2, 20)})
+	create(Measurement{UserID: user.ID, Kind: "weight", Value: 78000, Unit: "g", MeasuredAt: at(5, 8)})
+	create(Measurement{UserID: other.ID, Kind: "weight", Value: 60, MeasuredAt: at(2, 8)})
+
+	all, err := s.GetMeasurements(t.Context(), user.ID, MeasurementFilter{})
+	if err != nil {
+		t.Fatalf("GetMeasurements failed: %v", err)
+	}
+	if len(all) != 5 || all[0].ID != first.ID {
+		t.Errorf("Expected 5 measurements, oldest first, got %+v", all)
+	}
+	weights, err := s.GetMeasurements(t.Context(), user.ID, MeasurementFilter{Kind: "weight", From: at(2, 0), To: at(3, 0)})
+	if err != nil {
+		t.Fatalf("GetMeasurements failed: %v", err)
+	}
+	if len(weights) != 2 || weights[0].Value != 81 || weights[1].Value != 79 {
+		t.Errorf("Expected the two readings of the 2nd, got %+v", weights)
+	}
+
+	// 4. Trends group readings by local day, in the unit of the latest reading
+	period, err := RangePeriod(at(2, 0), at(5, 0))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	trends, err := s.GetMeasurementTrends(t.Context(), user.ID, "", period)
+	if err != nil {
+		t.Fatalf("GetMeasurementTrends failed: %v", err)
+	}
+	if len(trends) != 1 || trends[0].Kind != "weight" {
+		t.Fatalf("Expected only a weight trend (the waist reading is before the period), got %+v", trends)
+	}
+	trend := trends[0]
+	if trend.Unit != "g" || len(trend.Days) != 2 {
+		t.Fatalf("Expected 2 days in g, got %+v", trend)
+	}
+	day2, day5 := trend.Days[0], trend.Days[1]
+	if day2.Date != "2024-03-02" || day2.Readings != 2 || day2.Value != 80000 {
+		t.Errorf("Expected 80000g from 2 readings on the 2nd, got %+v", day2)
+	}
+	// The 1st seeds the trend at 80kg, so the 2nd's average leaves it there;
+	// the 5th comes three days later and moves it by 1-0.9^3 of the gap.
+	if math.Abs(day2.Trend-80000) > 1e-6 {
+		t.Errorf("Expected trend 80000 on the 2nd, got %v", day2.Trend)
+	}
+	want := 80000 + (1-math.Pow(0.9, 3))*(78000-80000)
+	if day5.Date != "2024-03-05" || math.Abs(day5.Trend-want) > 1e-6 {
+		t.Errorf("Expected trend %v on the 5th, got %+v", want, day5)
+	}
+
+	// 5. Updates are scoped to the owner
+	first.Value = 82
+	stolen := *first
+	stolen.UserID = other.ID
+	if _, err := s.UpdateMeasurement(t.Context(), stolen); !errors.Is(err, ErrMeasurementNotFound) {
+		t.Errorf("Expected ErrMeasurementNotFound for another user's measurement, got %v", err)
+	}
+	updated, err := s.UpdateMeasurement(t.Context(), *first)
+	if err != nil {
+		t.Fatalf("UpdateMeasurement failed: %v", err)
+	}
+	if updated.Value != 82 || !updated.MeasuredAt.Equal(first.MeasuredAt) {
+		t.Errorf("Expected 82kg at %v, got %+v", first.MeasuredAt, updated)
+	}
+
+	// 6. Deleting another user's measurement does nothing
+	count := func() int {
+		t.Helper()
+		all, err := s.GetMeasurements(t.Context(), user.ID, MeasurementFilter{})
+		if err != nil {
+			t.Fatalf("GetMeasurements failed: %v", err)
+		}
+		return len(all)
+	}
+	if err := s.DeleteMeasurement(t.Context(), other.ID, waist.ID); err != nil {
+		t.Fatalf("DeleteMeasurement failed: %v", err)
+	}
+	if n := count(); n != 5 {
+		t.Errorf("Expected 5 measurements after another user's delete, got %d", n)
+	}
+	if err := s.DeleteMeasurement(t.Context(), user.ID, waist.ID); err != nil {
+		t.Fatalf("DeleteMeasurement failed: %v", err)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("Expected 4 measurements after delete, got %d", n)
+	}
+}
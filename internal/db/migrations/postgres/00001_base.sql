@@ -0,0 +1,90 @@
+-- +goose Up
+CREATE TABLE users (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    disabled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE user_credentials (
+    id BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    public_key BYTEA NOT NULL,
+    attestation_type TEXT NOT NULL,
+    aaguid TEXT NOT NULL,
+    sign_count BIGINT NOT NULL,
+    transports TEXT,
+    backup_eligible BOOLEAN NOT NULL,
+    backup_state BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE foods (
+    id UUID PRIMARY KEY,
+    creator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    version INTEGER NOT NULL,
+    is_current BOOLEAN NOT NULL,
+    name TEXT NOT NULL,
+    calories DOUBLE PRECISION NOT NULL,
+    protein DOUBLE PRECISION NOT NULL,
+    carbs DOUBLE PRECISION NOT NULL,
+    fat DOUBLE PRECISION NOT NULL,
+    type TEXT NOT NULL,
+    measurement_unit TEXT NOT NULL,
+    measurement_amount DOUBLE PRECISION NOT NULL,
+    public BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE TABLE food_nutrients (
+    food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL,
+    PRIMARY KEY (food_id, name)
+);
+
+CREATE TABLE recipe_items (
+    recipe_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES foods(id) ON DELETE RESTRICT,
+    amount DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (recipe_id, ingredient_id)
+);
+
+CREATE TABLE food_log_entries (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    food_id UUID NOT NULL REFERENCES foods(id) ON DELETE RESTRICT,
+    amount DOUBLE PRECISION NOT NULL,
+    meal_tag TEXT NOT NULL,
+    logged_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_user_credentials_user_id ON user_credentials(user_id);
+CREATE INDEX idx_foods_family_id ON foods(family_id);
+CREATE INDEX idx_foods_name ON foods(name);
+CREATE INDEX idx_foods_creator_id ON foods(creator_id);
+CREATE INDEX idx_recipe_items_ingredient_id ON recipe_items(ingredient_id);
+CREATE INDEX idx_food_log_entries_user_id_logged_at ON food_log_entries(user_id, logged_at);
+
+-- +goose Down
+DROP INDEX idx_food_log_entries_user_id_logged_at;
+DROP INDEX idx_recipe_items_ingredient_id;
+DROP INDEX idx_foods_name;
+DROP INDEX idx_foods_family_id;
+DROP INDEX idx_foods_creator_id;
+DROP INDEX idx_user_credentials_user_id;
+
+DROP TABLE food_log_entries;
+DROP TABLE recipe_items;
+DROP TABLE food_nutrients;
+DROP TABLE foods;
+DROP TABLE user_credentials;
+DROP TABLE users;
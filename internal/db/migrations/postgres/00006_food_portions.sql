@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE food_portions (
+    id UUID PRIMARY KEY,
+    food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_food_portions_food_id ON food_portions(food_id);
+
+-- Entries logged by portion keep the portion and quantity for display. amount
+-- and unit already hold the equivalent, so portion_id is cleared rather than
+-- constrained when a portion is deleted.
+ALTER TABLE food_log_entries ADD COLUMN portion_id UUID;
+ALTER TABLE food_log_entries ADD COLUMN quantity DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE food_log_entries DROP COLUMN quantity;
+ALTER TABLE food_log_entries DROP COLUMN portion_id;
+
+DROP INDEX idx_food_portions_food_id;
+DROP TABLE food_portions;
@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE foods ADD COLUMN brand TEXT NOT NULL DEFAULT '';
+
+-- One row per food version, written by the application alongside the food.
+-- Versions never change, so rows are only ever inserted.
+CREATE TABLE food_search (
+    food_id UUID PRIMARY KEY REFERENCES foods(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    nutrients TEXT NOT NULL,
+    document TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') ||
+        setweight(to_tsvector('simple', brand), 'B') ||
+        setweight(to_tsvector('simple', nutrients), 'C')
+    ) STORED
+);
+
+CREATE INDEX idx_food_search_document ON food_search USING GIN (document);
+
+INSERT INTO food_search (food_id, name, brand, nutrients)
+SELECT f.id, f.name, f.brand,
+    COALESCE((SELECT string_agg(n.name, ' ') FROM food_nutrients n WHERE n.food_id = f.id), '')
+FROM foods f;
+
+-- +goose Down
+DROP INDEX idx_food_search_document;
+DROP TABLE food_search;
+
+ALTER TABLE foods DROP COLUMN brand;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- An IANA time zone name such as 'America/Los_Angeles'; empty means UTC.
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN timezone;
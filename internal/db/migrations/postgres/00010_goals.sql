@@ -0,0 +1,25 @@
+-- +goose Up
+-- A goal is a set of daily targets that applies from effective_from (a
+-- YYYY-MM-DD day in the user's time zone) until the user's next goal. The day
+-- is kept as text, like on SQLite, so it compares the same on both backends.
+CREATE TABLE goals (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    effective_from TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (user_id, effective_from)
+);
+
+-- min_amount and max_amount are nullable for one-sided bounds.
+CREATE TABLE goal_targets (
+    goal_id UUID NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    nutrient TEXT NOT NULL,
+    unit TEXT NOT NULL,
+    min_amount DOUBLE PRECISION,
+    max_amount DOUBLE PRECISION,
+    PRIMARY KEY (goal_id, nutrient, unit)
+);
+
+-- +goose Down
+DROP TABLE goal_targets;
+DROP TABLE goals;
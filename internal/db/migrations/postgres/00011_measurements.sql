@@ -0,0 +1,16 @@
+-- +goose Up
+-- One body measurement reading, kept in the unit it was taken in.
+CREATE TABLE measurements (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL,
+    measured_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_measurements_user_id_kind_measured_at ON measurements(user_id, kind, measured_at);
+
+-- +goose Down
+DROP INDEX idx_measurements_user_id_kind_measured_at;
+DROP TABLE measurements;
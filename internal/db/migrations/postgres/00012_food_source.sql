@@ -0,0 +1,9 @@
+-- +goose Up
+-- Foods forked from another family record the family and version they were
+-- copied from; both are NULL for original foods.
+ALTER TABLE foods ADD COLUMN source_family_id UUID;
+ALTER TABLE foods ADD COLUMN source_version INTEGER;
+
+-- +goose Down
+ALTER TABLE foods DROP COLUMN source_version;
+ALTER TABLE foods DROP COLUMN source_family_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Every food family has exactly one current version. Families that concurrent
+-- updates left with several keep the newest, and families left with none get
+-- their newest back.
+UPDATE foods SET is_current = false
+WHERE is_current = true AND EXISTS (
+    SELECT 1 FROM foods newer
+    WHERE newer.family_id = foods.family_id AND newer.is_current = true
+    AND (newer.version > foods.version OR (newer.version = foods.version AND newer.id > foods.id))
+);
+UPDATE foods SET is_current = true
+WHERE NOT EXISTS (
+    SELECT 1 FROM foods cur
+    WHERE cur.family_id = foods.family_id AND cur.is_current = true
+) AND NOT EXISTS (
+    SELECT 1 FROM foods newer
+    WHERE newer.family_id = foods.family_id
+    AND (newer.version > foods.version OR (newer.version = foods.version AND newer.id > foods.id))
+);
+CREATE UNIQUE INDEX idx_foods_family_id_current ON foods(family_id) WHERE is_current = true;
+
+-- +goose Down
+DROP INDEX idx_foods_family_id_current;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE recipe_items ADD COLUMN family_id TEXT;
+ALTER TABLE recipe_items ADD COLUMN follow_latest BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE recipe_items
+SET family_id = (SELECT family_id FROM foods WHERE foods.id = recipe_items.ingredient_id);
+
+CREATE INDEX idx_recipe_items_family_id ON recipe_items(family_id);
+
+-- +goose Down
+DROP INDEX idx_recipe_items_family_id;
+
+ALTER TABLE recipe_items DROP COLUMN follow_latest;
+ALTER TABLE recipe_items DROP COLUMN family_id;
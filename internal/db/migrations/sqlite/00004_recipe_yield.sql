@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE foods ADD COLUMN yield_weight REAL NOT NULL DEFAULT 0;
+ALTER TABLE foods ADD COLUMN servings REAL NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE foods DROP COLUMN servings;
+ALTER TABLE foods DROP COLUMN yield_weight;
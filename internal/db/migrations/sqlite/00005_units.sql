@@ -0,0 +1,10 @@
+-- +goose Up
+-- An empty unit means the food's own measurement_unit.
+ALTER TABLE foods ADD COLUMN density REAL NOT NULL DEFAULT 0;
+ALTER TABLE recipe_items ADD COLUMN unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE food_log_entries ADD COLUMN unit TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE food_log_entries DROP COLUMN unit;
+ALTER TABLE recipe_items DROP COLUMN unit;
+ALTER TABLE foods DROP COLUMN density;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE foods ADD COLUMN brand TEXT NOT NULL DEFAULT '';
+
+-- One row per food version, written by the application alongside the food.
+-- Versions never change, so rows are only ever inserted.
+CREATE VIRTUAL TABLE food_search USING fts5(
+    food_id UNINDEXED,
+    name,
+    brand,
+    nutrients,
+    tokenize = 'unicode61 remove_diacritics 2',
+    prefix = '2 3'
+);
+
+INSERT INTO food_search (food_id, name, brand, nutrients)
+SELECT f.id, f.name, f.brand,
+    COALESCE((SELECT group_concat(n.name, ' ') FROM food_nutrients n WHERE n.food_id = f.id), '')
+FROM foods f;
+
+-- +goose Down
+DROP TABLE food_search;
+
+ALTER TABLE foods DROP COLUMN brand;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The first day of the user's week as a lowercase English day name, such as
+-- 'sunday'; empty means Monday.
+ALTER TABLE users ADD COLUMN week_start TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN week_start;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- A goal is a set of daily targets that applies from effective_from (a
+-- YYYY-MM-DD day in the user's time zone) until the user's next goal.
+CREATE TABLE goals (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    effective_from TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, effective_from)
+);
+
+-- min_amount and max_amount are nullable for one-sided bounds.
+CREATE TABLE goal_targets (
+    goal_id TEXT NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    nutrient TEXT NOT NULL,
+    unit TEXT NOT NULL,
+    min_amount REAL,
+    max_amount REAL,
+    PRIMARY KEY (goal_id, nutrient, unit)
+);
+
+-- +goose Down
+DROP TABLE goal_targets;
+DROP TABLE goals;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- One body measurement reading, kept in the unit it was taken in.
+CREATE TABLE measurements (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    value REAL NOT NULL,
+    unit TEXT NOT NULL,
+    measured_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_measurements_user_id_kind_measured_at ON measurements(user_id, kind, measured_at);
+
+-- +goose Down
+DROP INDEX idx_measurements_user_id_kind_measured_at;
+DROP TABLE measurements;
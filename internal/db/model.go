@@ -15,6 +15,8 @@ import (
 //	email (Unique)
 //	disabled_at (Nullable)
 //	created_at
+//	timezone (IANA name; empty means UTC)
+//	week_start (Day name such as 'sunday'; empty means Monday)
 type UserID uuid.UUID
 type User struct {
 	ID         UserID     `json:"id"`
@@ -22,6 +24,8 @@ type User struct {
 	Email      string     `json:"email"`
 	DisabledAt *time.Time `json:"disabled_at"`
 	CreatedAt  time.Time  `json:"created_at"`
+	TimeZone   string     `json:"timezone"`
+	WeekStart  string     `json:"week_start"`
 }
 
 // UserCredentials (WebAuthn)
@@ -59,8 +63,9 @@ type UserCredential struct {
 //	id (Version UUID)
 //	family_id (UUID - links versions together)
 //	version (Integer)
-//	is_current (Boolean)
+//	is_current (Boolean - exactly one version per family)
 //	name
+//	brand
 //	calories
 //	protein
 //	carbs
@@ -68,6 +73,11 @@ type UserCredential struct {
 //	type (Enum: 'food', 'recipe')
 //	measurement_unit (e.g. 'g', 'ml', 'serving')
 //	measurement_amount (e.g. 100)
+//	yield_weight (Recipes: total cooked weight in grams, 0 if unknown)
+//	servings (Recipes: servings the recipe makes, 0 if unknown)
+//	density (g/ml, 0 if unknown - needed to convert between mass and volume)
+//	source_family_id (Nullable - the family this food was forked from)
+//	source_version (Nullable - the version of that family it was copied from)
 //	created_at
 //	deleted_at
 type FoodID uuid.UUID
@@ -79,6 +89,7 @@ type Food struct {
 	Version           int            `json:"version"`
 	IsCurrent         bool           `json:"is_current"`
 	Name              string         `json:"name"`
+	Brand             string         `json:"brand"`
 	Calories          float64        `json:"calories"`
 	Protein           float64        `json:"protein"`
 	Carbs             float64        `json:"carbs"`
@@ -87,10 +98,31 @@ type Food struct {
 	MeasurementUnit   string         `json:"measurement_unit"`
 	MeasurementAmount float64        `json:"measurement_amount"`
 	Public            bool           `json:"public"`
+	YieldWeight       float64        `json:"yield_weight,omitempty"`
+	Servings          float64        `json:"servings,omitempty"`
+	Density           float64        `json:"density,omitempty"`
 	Ingredients       []RecipeItems  `json:"ingredients,omitempty"`
 	Nutrients         []FoodNutrient `json:"nutrients,omitempty"`
+	Portions          []FoodPortion  `json:"portions,omitempty"`
+	SourceFamilyID    *FoodFamilyID  `json:"source_family_id,omitempty"`
+	SourceVersion     *int           `json:"source_version,omitempty"`
 	CreatedAt         time.Time      `json:"created_at"`
 	DeletedAt         *time.Time     `json:"deleted_at"`
+
+	// Computed for recipes when they are read, never stored.
+	RawWeight   float64    `json:"raw_weight,omitempty"`
+	CookingLoss float64    `json:"cooking_loss,omitempty"`
+	PerServing  *Nutrition `json:"per_serving,omitempty"`
+	Per100g     *Nutrition `json:"per_100g,omitempty"`
+}
+
+// Nutrition is the macro and micronutrient content of some quantity of a food.
+type Nutrition struct {
+	Calories  float64        `json:"calories"`
+	Protein   float64        `json:"protein"`
+	Carbs     float64        `json:"carbs"`
+	Fat       float64        `json:"fat"`
+	Nutrients []FoodNutrient `json:"nutrients,omitempty"`
 }
 
 // FoodNutrients (Micro-nutrients)
@@ -106,16 +138,40 @@ type FoodNutrient struct {
 	Unit   string  `json:"unit"`
 }
 
+// FoodPortions (Named portions of a food version, copied to each new version)
+//
+//	id
+//	food_id
+//	name (e.g. '1 slice', '1 medium apple')
+//	amount (e.g. 30)
+//	unit (e.g. 'g' - must convert to the food's measurement_unit)
+//	created_at
+type FoodPortionID uuid.UUID
+type FoodPortion struct {
+	ID        FoodPortionID `json:"id"`
+	FoodID    FoodID        `json:"food_id"`
+	Name      string        `json:"name"`
+	Amount    float64       `json:"amount"`
+	Unit      string        `json:"unit"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
 // RecipeItems (Join Table)
 //
 //	recipe_id (FK to foods.id)
 //	ingredient_id (FK to foods.id)
+//	family_id (ingredient's family)
+//	follow_latest (Boolean - use the family's current version instead of ingredient_id)
 //	amount
+//	unit (empty means the ingredient's measurement_unit)
 type RecipeID uuid.UUID
 type RecipeItems struct {
-	RecipeID     RecipeID `json:"recipe_id"`
-	IngredientID FoodID   `json:"ingredient_id"`
-	Amount       float64  `json:"amount"`
+	RecipeID     RecipeID     `json:"recipe_id"`
+	IngredientID FoodID       `json:"ingredient_id"`
+	FamilyID     FoodFamilyID `json:"family_id"`
+	FollowLatest bool         `json:"follow_latest"`
+	Amount       float64      `json:"amount"`
+	Unit         string       `json:"unit"`
 }
 
 // Logs
@@ -124,6 +180,9 @@ type RecipeItems struct {
 //	user_id
 //	food_id (Specific version)
 //	amount
+//	unit (empty means the food's measurement_unit)
+//	portion_id (Nullable - set when logged as a quantity of a portion)
+//	quantity (number of portions)
 //	meal_tag (String: 'breakfast', 'lunch', etc.)
 //	logged_at (Date/Time)
 //	created_at
@@ -134,10 +193,73 @@ type FoodLogEntry struct {
 	UserID    UserID         `json:"user_id"`
 	FoodID    FoodID         `json:"food_id"`
 	Amount    float64        `json:"amount"`
+	Unit      string         `json:"unit"`
+	PortionID *FoodPortionID `json:"portion_id,omitempty"`
+	Quantity  float64        `json:"quantity,omitempty"`
 	MealTag   string         `json:"meal_tag"`
 	LoggedAt  time.Time      `json:"logged_at"`
 	CreatedAt time.Time      `json:"created_at"`
 	DeletedAt *time.Time     `json:"deleted_at"`
+
+	// Food is filled in only when requested with LogFilter.IncludeFood.
+	Food *LoggedFood `json:"food,omitempty"`
+}
+
+// LoggedFood is the food behind a log entry, with its nutrition scaled to the
+// logged amount.
+type LoggedFood struct {
+	Name  string `json:"name"`
+	Brand string `json:"brand,omitempty"`
+	Nutrition
+}
+
+// Goals (Daily targets, versioned by the day they take effect)
+//
+//	id
+//	user_id
+//	effective_from (YYYY-MM-DD in the user's time zone)
+//	created_at
+type GoalID uuid.UUID
+type Goal struct {
+	ID            GoalID       `json:"id"`
+	UserID        UserID       `json:"user_id"`
+	EffectiveFrom string       `json:"effective_from"`
+	Targets       []GoalTarget `json:"targets"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// GoalTargets
+//
+//	goal_id
+//	nutrient ('calories', 'protein', 'carbs', 'fat' or a micronutrient name)
+//	unit ('kcal' for calories; 'g' or '%' of calories for macros; any unit for micronutrients)
+//	min_amount (Nullable)
+//	max_amount (Nullable)
+type GoalTarget struct {
+	Nutrient string   `json:"nutrient"`
+	Unit     string   `json:"unit"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// Measurements (Body measurement readings)
+//
+//	id
+//	user_id
+//	kind ('weight', 'body_fat', 'waist', 'hips', 'chest', 'neck', 'arm' or 'thigh')
+//	value
+//	unit (mass for weight, '%' for body_fat, length for the rest)
+//	measured_at (Date/Time)
+//	created_at
+type MeasurementID uuid.UUID
+type Measurement struct {
+	ID         MeasurementID `json:"id"`
+	UserID     UserID        `json:"user_id"`
+	Kind       string        `json:"kind"`
+	Value      float64       `json:"value"`
+	Unit       string        `json:"unit"`
+	MeasuredAt time.Time     `json:"measured_at"`
+	CreatedAt  time.Time     `json:"created_at"`
 }
 
 // SQL Driver Support
@@ -182,6 +304,36 @@ func (id *RecipeID) Scan(src any) error {
 	return nil
 }
 
+func (id FoodPortionID) Value() (driver.Value, error) { return uuid.UUID(id).Value() }
+func (id *FoodPortionID) Scan(src any) error {
+	var u uuid.UUID
+	if err := u.Scan(src); err != nil {
+		return err
+	}
+	*id = FoodPortionID(u)
+	return nil
+}
+
+func (id GoalID) Value() (driver.Value, error) { return uuid.UUID(id).Value() }
+func (id *GoalID) Scan(src any) error {
+	var u uuid.UUID
+	if err := u.Scan(src); err != nil {
+		return err
+	}
+	*id = GoalID(u)
+	return nil
+}
+
+func (id MeasurementID) Value() (driver.Value, error) { return uuid.UUID(id).Value() }
+func (id *MeasurementID) Scan(src any) error {
+	var u uuid.UUID
+	if err := u.Scan(src); err != nil {
+		return err
+	}
+	*id = MeasurementID(u)
+	return nil
+}
+
 func (id FoodLogEntryID) Value() (driver.Value, error) { return uuid.UUID(id).Value() }
 func (id *FoodLogEntryID) Scan(src any) error {
 	var u uuid.UUID
@@ -242,6 +394,42 @@ func (id *RecipeID) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (id GoalID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uuid.UUID(id))
+}
+func (id *GoalID) UnmarshalJSON(data []byte) error {
+	var u uuid.UUID
+	if err := json.Unmarshal(data, &u); err != nil {
+		return err
+	}
+	*id = GoalID(u)
+	return nil
+}
+
+func (id MeasurementID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uuid.UUID(id))
+}
+func (id *MeasurementID) UnmarshalJSON(data []byte) error {
+	var u uuid.UUID
+	if err := json.Unmarshal(data, &u); err != nil {
+		return err
+	}
+	*id = MeasurementID(u)
+	return nil
+}
+
+func (id FoodPortionID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uuid.UUID(id))
+}
+func (id *FoodPortionID) UnmarshalJSON(data []byte) error {
+	var u uuid.UUID
+	if err := json.Unmarshal(data, &u); err != nil {
+		return err
+	}
+	*id = FoodPortionID(u)
+	return nil
+}
+
 func (id FoodLogEntryID) MarshalJSON() ([]byte, error) {
 	return json.Marshal(uuid.UUID(id))
 }
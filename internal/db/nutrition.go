@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrIngredientNotFound is returned when a recipe references a food version
+// that does not exist.
+var ErrIngredientNotFound = errors.New("ingredient not found")
+
+// nutritionResolver loads foods and rolls recipe nutrition up from their
+// ingredients. Results are memoized, so a resolver should live no longer than
+// a single store call.
+type nutritionResolver struct {
+	s        *sqlStore
+	resolved map[FoodID]*Food
+	visiting []*Food
+}
+
+func newNutritionResolver(s *sqlStore) *nutritionResolver {
+	return &nutritionResolver{
+		s:        s,
+		resolved: make(map[FoodID]*Food),
+	}
+}
+
+// resolve returns the food with id, or nil if it does not exist. For recipes
+// the macros and nutrients are replaced by the sum of the ingredients, and the
+// yield fields are filled in.
+func (r *nutritionResolver) resolve(ctx context.Context, id FoodID) (*Food, error) {
+	if f, ok := r.resolved[id]; ok {
+		return f, nil
+	}
+	for i, f := range r.visiting {
+		if f.ID == id {
+			return nil, &RecipeCycleError{Path: append(foodNames(r.visiting[i:]), f.Name)}
+		}
+	}
+
+	f, err := r.s.getFood(ctx, id)
+	if err != nil || f == nil {
+		return f, err
+	}
+
+	var rawWeight float64
+	if len(f.Ingredients) > 0 {
+		r.visiting = append(r.visiting, f)
+		total, err := r.rollup(ctx, f.Ingredients)
+		r.visiting = r.visiting[:len(r.visiting)-1]
+		if err != nil {
+			return nil, err
+		}
+		f.Calories, f.Protein, f.Carbs, f.Fat = total.Calories, total.Protein, total.Carbs, total.Fat
+		f.Nutrients = total.Nutrients
+		for i := range f.Nutrients {
+			f.Nutrients[i].FoodID = f.ID
+		}
+		rawWeight = total.RawWeight
+	}
+	f.applyYield(rawWeight)
+
+	r.resolved[id] = f
+	return f, nil
+}
+
+// resolveMacros replaces the stored macros of f, if it is a recipe, with the
+// ones GetFood reports. Stored macros are only a snapshot from when the
+// version was written, so they go stale once an ingredient followed with
+// follow_latest changes.
+func (r *nutritionResolver) resolveMacros(ctx context.Context, f *Food) error {
+	if f.Type != "recipe" {
+		return nil
+	}
+	resolved, err := r.resolve(ctx, f.ID)
+	if err != nil || resolved == nil {
+		return err
+	}
+	f.Calories, f.Protein, f.Carbs, f.Fat = resolved.Calories, resolved.Protein, resolved.Carbs, resolved.Fat
+	return nil
+}
+
+// rollup sums the nutrition of a list of recipe items. Each ingredient
+// contributes amount/measurement_amount times its own nutrition, after the
+// amount is converted into the ingredient's measurement unit. RawWeight is
+// set to the ingredients' total weight in grams when all of them are known.
+func (r *nutritionResolver) rollup(ctx context.Context, items []RecipeItems) (Food, error) {
+	var total Food
+	weighed := true
+	for _, item := range items {
+		ing, err := r.resolve(ctx, item.IngredientID)
+		if err != nil {
+			return Food{}, err
+		}
+		if ing == nil {
+			return Food{}, fmt.Errorf("%w: %s", ErrIngredientNotFound, uuid.UUID(item.IngredientID))
+		}
+		amount, err := ing.measure(item.Amount, item.Unit)
+		if err != nil {
+			return Food{}, err
+		}
+		total.addScaled(ing, servingsOf(ing, amount))
+		if grams, ok := weightOf(ing, amount); ok {
+			total.RawWeight += grams
+		} else {
+			weighed = false
+		}
+	}
+	if !weighed {
+		total.RawWeight = 0
+	}
+	return total, nil
+}
+
+// servingsOf converts an amount in the food's measurement unit into a
+// multiple of its measurement_amount. A zero measurement_amount counts as 1,
+// matching how stats have always treated it.
+func servingsOf(f *Food, amount float64) float64 {
+	if f.MeasurementAmount == 0 {
+		return amount
+	}
+	return amount / f.MeasurementAmount
+}
+
+// addScaled adds factor times other's macros and nutrients to f. Nutrients
+// are merged by name and unit.
+func (f *Food) addScaled(other *Food, factor float64) {
+	f.Calories += other.Calories * factor
+	f.Protein += other.Protein * factor
+	f.Carbs += other.Carbs * factor
+	f.Fat += other.Fat * factor
+
+	for _, n := range other.Nutrients {
+		merged := false
+		for i := range f.Nutrients {
+			if f.Nutrients[i].Name == n.Name && f.Nutrients[i].Unit == n.Unit {
+				f.Nutrients[i].Amount += n.Amount * factor
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			f.Nutrients = append(f.Nutrients, FoodNutrient{Name: n.Name, Amount: n.Amount * factor, Unit: n.Unit})
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultPageLimit is used when PageOptions.Limit is zero.
+	DefaultPageLimit = 50
+	// MaxPageLimit caps PageOptions.Limit.
+	MaxPageLimit = 200
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor that was not produced by the
+	// same listing and sort.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned for a sort the listing does not support.
+	ErrInvalidSort = errors.New("invalid sort")
+)
+
+// PageOptions selects one page of a listing.
+type PageOptions struct {
+	// Limit is the page size. Zero means DefaultPageLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first.
+	Cursor string
+	// Sort names the order; each listing documents the ones it supports.
+	Sort string
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (o PageOptions) limit() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultPageLimit
+	case o.Limit > MaxPageLimit:
+		return MaxPageLimit
+	default:
+		return o.Limit
+	}
+}
+
+// cursor is the position after the last item of a page: the value of the
+// sort key and the item's id, which breaks ties. Ranked listings with no
+// stable key use Offset instead, and record the Query it counts into.
+type cursor struct {
+	Sort   string `json:"s"`
+	Key    string `json:"k,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Offset int    `json:"o,omitempty"`
+	Query  string `json:"q,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor, returning nil for the first page.
+func decodeCursor(s, sort string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// id returns the cursor's tie-breaking id.
+func (c *cursor) id() (uuid.UUID, error) {
+	id, err := uuid.Parse(c.ID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// newPage trims a result fetched with one extra row down to limit and sets
+// the next cursor from the last item kept.
+func newPage[T any](items []T, limit int, next func(last T) cursor) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	page := Page[T]{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = next(page.Items[limit-1]).encode()
+	}
+	return page
+}
+
+func invalidSort(sort string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSort, sort)
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// collectFoods follows next cursors until the last page.
+func collectFoods(t *testing.T, s Store, user *User, opts PageOptions) []string {
+	t.Helper()
+	var names []string
+	for range 10 {
+		page, err := s.GetFoods(t.Context(), user.ID, AllFoods, opts)
+		if err != nil {
+			t.Fatalf("GetFoods (%s) failed: %v", opts.Sort, err)
+		}
+		if len(page.Items) > opts.Limit {
+			t.Fatalf("Expected at most %d foods, got %d", opts.Limit, len(page.Items))
+		}
+		for _, f := range page.Items {
+			names = append(names, f.Name)
+		}
+		if page.NextCursor == "" {
+			return names
+		}
+		opts.Cursor = page.NextCursor
+	}
+	t.Fatalf("GetFoods (%s) did not reach the last page", opts.Sort)
+	return nil
+}
+
+func testPagination(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// Created in this order; two share a name to exercise the id tie-break.
+	foods := make(map[string]*Food)
+	for _, name := range []string{"Cherry", "Apple", "Banana", "Apple", "Date"} {
+		foods[name] = createTestIngredient(t, s, user, name)
+	}
+
+	// 1. By name, across pages
+	names := collectFoods(t, s, user, PageOptions{Limit: 2})
+	if want := []string{"Apple", "Apple", "Banana", "Cherry", "Date"}; !slices.Equal(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	// 2. Newest first, by creation rather than the latest update
+	cherry := foods["Cherry"]
+	if _, err := s.UpdateFood(t.Context(), cherry.ID, *cherry); err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	names = collectFoods(t, s, user, PageOptions{Limit: 2, Sort: "created_at"})
+	if want := []string{"Date", "Apple", "Banana", "Apple", "Cherry"}; !slices.Equal(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	// 3. Most logged first, ties in creation order
+	for range 3 {
+		createTestLogEntry(t, s, user, foods["Banana"], 100, time.Now())
+	}
+	createTestLogEntry(t, s, user, foods["Date"], 100, time.Now())
+	names = collectFoods(t, s, user, PageOptions{Limit: 2, Sort: "most_logged"})
+	if want := []string{"Banana", "Date", "Cherry", "Apple", "Apple"}; !slices.Equal(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	// 4. Log entries by logged_at, including entries logged at the same time
+	base := time.Now().UTC().Truncate(24 * time.Hour).Add(time.Hour)
+	other := createTestUser(t, s)
+	apple := createTestIngredient(t, s, other, "Apple")
+	var want []FoodLogEntryID
+	for i, offset := range []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		entry := createTestLogEntry(t, s, other, apple, float64(i+1), base.Add(offset))
+		want = append(want, entry.ID)
+	}
+	var got []FoodLogEntryID
+	opts := PageOptions{Limit: 2}
+	for range 10 {
+		page, err := s.GetFoodLogEntries(t.Context(), other.ID, DayFilter(base), opts)
+		if err != nil {
+			t.Fatalf("GetFoodLogEntries failed: %v", err)
+		}
+		for _, e := range page.Items {
+			got = append(got, e.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected entries in logged order %v, got %v", want, got)
+	}
+
+	// 5. Search results page by offset within the same query
+	page, err := s.SearchFoods(t.Context(), user.ID, "app", AllFoods, PageOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchFoods failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected a full first page with a cursor, got %d items", len(page.Items))
+	}
+	firstID := page.Items[0].ID
+	if _, err := s.SearchFoods(t.Context(), user.ID, "apple", AllFoods, PageOptions{Limit: 1, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a cursor from another query, got %v", err)
+	}
+	page, err = s.SearchFoods(t.Context(), user.ID, "App", AllFoods, PageOptions{Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("SearchFoods (page 2) failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "" || page.Items[0].ID == firstID {
+		t.Errorf("Expected a last page with the other apple, got %+v", page)
+	}
+
+	// 6. Bad cursors and sorts are rejected
+	first, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetFoods failed: %v", err)
+	}
+	if _, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{Cursor: first.NextCursor, Sort: "created_at"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a cursor from another sort, got %v", err)
+	}
+	if _, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{Sort: "calories"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(base), PageOptions{Sort: "name"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort for log entries, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrPortionNotFound is returned when a portion does not exist for the
+	// given food version.
+	ErrPortionNotFound = errors.New("portion not found")
+	// ErrInvalidPortion is returned when a portion has no name or a
+	// non-positive amount.
+	ErrInvalidPortion = errors.New("portions need a name and a positive amount")
+)
+
+// GetFoodPortions lists the portions of a food version.
+func (s *sqlStore) GetFoodPortions(ctx context.Context, foodID FoodID) ([]FoodPortion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getFoodPortions(ctx, foodID)
+}
+
+func (s *sqlStore) getFoodPortions(ctx context.Context, foodID FoodID) ([]FoodPortion, error) {
+	query := `
+		SELECT id, food_id, name, amount, unit, created_at
+		FROM food_portions
+		WHERE food_id = ?
+		ORDER BY name
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), foodID)
+	if err != nil {
+		return nil, fmt.Errorf("listing food portions: %w", err)
+	}
+	defer rows.Close()
+
+	var portions []FoodPortion
+	for rows.Next() {
+		var p FoodPortion
+		if err := rows.Scan(&p.ID, &p.FoodID, &p.Name, &p.Amount, &p.Unit, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning food portion: %w", err)
+		}
+		portions = append(portions, p)
+	}
+	return portions, rows.Err()
+}
+
+func (s *sqlStore) getFoodPortion(ctx context.Context, foodID FoodID, id FoodPortionID) (*FoodPortion, error) {
+	query := `
+		SELECT id, food_id, name, amount, unit, created_at
+		FROM food_portions
+		WHERE id = ? AND food_id = ?
+	`
+	var p FoodPortion
+	err := s.db.QueryRowContext(ctx, s.bind(query), id, foodID).Scan(&p.ID, &p.FoodID, &p.Name, &p.Amount, &p.Unit, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting food portion: %w", err)
+	}
+	return &p, nil
+}
+
+// CreateFoodPortion adds a portion to the food version portion.FoodID, which
+// must be the family's current version.
+func (s *sqlStore) CreateFoodPortion(ctx context.Context, portion FoodPortion) (*FoodPortion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.checkPortion(ctx, &portion); err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generating id: %w", err)
+	}
+	portion.ID = FoodPortionID(id)
+	if portion.CreatedAt.IsZero() {
+		portion.CreatedAt = time.Now()
+	}
+
+	query := "INSERT INTO food_portions (id, food_id, name, amount, unit, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err = s.db.ExecContext(ctx, s.bind(query), portion.ID, portion.FoodID, portion.Name, portion.Amount, portion.Unit, portion.CreatedAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("inserting food portion: %w", err)
+	}
+	return &portion, nil
+}
+
+// UpdateFoodPortion changes the name, amount and unit of an existing portion.
+func (s *sqlStore) UpdateFoodPortion(ctx context.Context, portion FoodPortion) (*FoodPortion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.checkPortion(ctx, &portion); err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE food_portions SET name = ?, amount = ?, unit = ? WHERE id = ? AND food_id = ?"
+	res, err := s.db.ExecContext(ctx, s.bind(query), portion.Name, portion.Amount, portion.Unit, portion.ID, portion.FoodID)
+	if err != nil {
+		return nil, fmt.Errorf("updating food portion: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("updating food portion: %w", err)
+	} else if n == 0 {
+		return nil, ErrPortionNotFound
+	}
+	return s.getFoodPortion(ctx, portion.FoodID, portion.ID)
+}
+
+// DeleteFoodPortion removes a portion, returning ErrPortionNotFound if the
+// food version has no such portion. Log entries that used it keep their
+// amount but no longer reference it.
+func (s *sqlStore) DeleteFoodPortion(ctx context.Context, foodID FoodID, id FoodPortionID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.portionFood(ctx, foodID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.bind("DELETE FROM food_portions WHERE id = ? AND food_id = ?"), id, foodID)
+	if err != nil {
+		return fmt.Errorf("deleting food portion: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting food portion: %w", err)
+	}
+	if n == 0 {
+		return ErrPortionNotFound
+	}
+	_, err = tx.ExecContext(ctx, s.bind("UPDATE food_log_entries SET portion_id = NULL WHERE portion_id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("detaching log entries from portion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing portion delete: %w", err)
+	}
+	return nil
+}
+
+// checkPortion validates a portion against its food. An empty unit defaults
+// to the food's measurement unit.
+func (s *sqlStore) checkPortion(ctx context.Context, portion *FoodPortion) error {
+	portion.Name = strings.TrimSpace(portion.Name)
+	if portion.Name == "" || portion.Amount <= 0 {
+		return ErrInvalidPortion
+	}
+	food, err := s.portionFood(ctx, portion.FoodID)
+	if err != nil {
+		return err
+	}
+	if portion.Unit == "" {
+		portion.Unit = food.MeasurementUnit
+	}
+	_, err = food.measure(portion.Amount, portion.Unit)
+	return err
+}
+
+// portionFood loads the food version whose portions are being changed. Only
+// the current version's portions may change: older versions are history that
+// log entries point to, so changing them returns ErrVersionConflict.
+func (s *sqlStore) portionFood(ctx context.Context, foodID FoodID) (*Food, error) {
+	food, err := s.getFood(ctx, foodID)
+	if err != nil {
+		return nil, err
+	}
+	if food == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFoodNotFound, uuid.UUID(foodID))
+	}
+	if !food.IsCurrent {
+		return nil, fmt.Errorf("%w: version %d", ErrVersionConflict, food.Version)
+	}
+	return food, nil
+}
+
+// applyPortion fills in the amount and unit of an entry logged as a quantity
+// of a portion. A zero quantity means one portion.
+func (s *sqlStore) applyPortion(ctx context.Context, entry *FoodLogEntry) error {
+	if entry.PortionID == nil {
+		return nil
+	}
+	portion, err := s.getFoodPortion(ctx, entry.FoodID, *entry.PortionID)
+	if err != nil {
+		return err
+	}
+	if portion == nil {
+		return fmt.Errorf("%w: %s", ErrPortionNotFound, uuid.UUID(*entry.PortionID))
+	}
+	if entry.Quantity == 0 {
+		entry.Quantity = 1
+	}
+	entry.Amount = entry.Quantity * portion.Amount
+	entry.Unit = portion.Unit
+	return nil
+}
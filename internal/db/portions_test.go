@@ -0,0 +1,138 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"azule.info/calorize/internal/units"
+)
+
+func testFoodPortions(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 100g: 100kcal, 10p, 10c, 2f
+	bread := createTestIngredient(t, s, user, "Bread")
+
+	// 1. Create portions
+	slice, err := s.CreateFoodPortion(t.Context(), FoodPortion{FoodID: bread.ID, Name: "1 slice", Amount: 30, Unit: "g"})
+	if err != nil {
+		t.Fatalf("CreateFoodPortion failed: %v", err)
+	}
+	loaf, err := s.CreateFoodPortion(t.Context(), FoodPortion{FoodID: bread.ID, Name: "1 loaf", Amount: 0.5, Unit: "kg"})
+	if err != nil {
+		t.Fatalf("CreateFoodPortion (loaf) failed: %v", err)
+	}
+	portions, err := s.GetFoodPortions(t.Context(), bread.ID)
+	if err != nil {
+		t.Fatalf("GetFoodPortions failed: %v", err)
+	}
+	if len(portions) != 2 || portions[0].Name != "1 loaf" || portions[1].Name != "1 slice" {
+		t.Errorf("Expected loaf and slice portions, got %+v", portions)
+	}
+
+	// 2. Invalid portions are rejected
+	if _, err := s.CreateFoodPortion(t.Context(), FoodPortion{FoodID: bread.ID, Name: "", Amount: 1}); !errors.Is(err, ErrInvalidPortion) {
+		t.Errorf("Expected ErrInvalidPortion, got %v", err)
+	}
+	if _, err := s.CreateFoodPortion(t.Context(), FoodPortion{FoodID: bread.ID, Name: "1 cup", Amount: 1, Unit: "cup"}); !errors.Is(err, units.ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible without a density, got %v", err)
+	}
+
+	// 3. Update
+	slice.Amount = 40
+	slice, err = s.UpdateFoodPortion(t.Context(), *slice)
+	if err != nil {
+		t.Fatalf("UpdateFoodPortion failed: %v", err)
+	}
+	if slice.Amount != 40 {
+		t.Errorf("Expected amount 40, got %f", slice.Amount)
+	}
+
+	// 4. Log by portion and quantity
+	entry, err := s.CreateFoodLogEntry(t.Context(), FoodLogEntry{
+		UserID:    user.ID,
+		FoodID:    bread.ID,
+		PortionID: &slice.ID,
+		Quantity:  2,
+		LoggedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateFoodLogEntry failed: %v", err)
+	}
+	if entry.Amount != 80 || entry.Unit != "g" {
+		t.Errorf("Expected 80 g, got %f %s", entry.Amount, entry.Unit)
+	}
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Now()))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Calories != 80 {
+		t.Errorf("Expected 80 calories, got %f", stats.Calories)
+	}
+
+	// 5. Portions are carried forward to new versions with new ids
+	next, err := s.UpdateFood(t.Context(), bread.ID, Food{CreatorID: user.ID, Name: "Bread", MeasurementUnit: "g", MeasurementAmount: 100, Calories: 250})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	fetched, err := s.GetFood(t.Context(), next.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if len(fetched.Portions) != 2 {
+		t.Fatalf("Expected 2 portions on the new version, got %d", len(fetched.Portions))
+	}
+	for _, p := range fetched.Portions {
+		if p.ID == slice.ID || p.ID == loaf.ID || p.FoodID != next.ID {
+			t.Errorf("Expected a new portion for the new version, got %+v", p)
+		}
+	}
+
+	// 6. A portion of another food cannot be used
+	other := createTestIngredient(t, s, user, "Butter")
+	_, err = s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: other.ID, PortionID: &slice.ID, LoggedAt: time.Now()})
+	if !errors.Is(err, ErrPortionNotFound) {
+		t.Errorf("Expected ErrPortionNotFound, got %v", err)
+	}
+
+	// 7. Portions of older versions cannot change, as log entries use them
+	slice.Amount = 35
+	if _, err := s.UpdateFoodPortion(t.Context(), *slice); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict updating an old version's portion, got %v", err)
+	}
+	if _, err := s.CreateFoodPortion(t.Context(), FoodPortion{FoodID: bread.ID, Name: "1 crust", Amount: 10}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict adding to an old version, got %v", err)
+	}
+	if err := s.DeleteFoodPortion(t.Context(), bread.ID, slice.ID); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict deleting an old version's portion, got %v", err)
+	}
+
+	// 8. Deleting a portion keeps the entries that used it
+	newSlice := fetched.Portions[1]
+	if newSlice.Name != "1 slice" {
+		t.Fatalf("Expected the new version's slice, got %+v", newSlice)
+	}
+	sliceEntry, err := s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: next.ID, PortionID: &newSlice.ID, LoggedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("CreateFoodLogEntry (new slice) failed: %v", err)
+	}
+	if err := s.DeleteFoodPortion(t.Context(), next.ID, newSlice.ID); err != nil {
+		t.Fatalf("DeleteFoodPortion failed: %v", err)
+	}
+	entriesPage, err := s.GetFoodLogEntries(t.Context(), user.ID, DayFilter(time.Now()), PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoodLogEntries failed: %v", err)
+	}
+	for _, e := range entriesPage.Items {
+		if e.ID == sliceEntry.ID && (e.PortionID != nil || e.Amount != 40) {
+			t.Errorf("Expected the entry to survive without its portion, got %+v", e)
+		}
+	}
+	if _, err := s.UpdateFoodPortion(t.Context(), newSlice); !errors.Is(err, ErrPortionNotFound) {
+		t.Errorf("Expected ErrPortionNotFound after delete, got %v", err)
+	}
+	if err := s.DeleteFoodPortion(t.Context(), next.ID, newSlice.ID); !errors.Is(err, ErrPortionNotFound) {
+		t.Errorf("Expected ErrPortionNotFound deleting again, got %v", err)
+	}
+}
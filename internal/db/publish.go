@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrNotPublishable is returned when a food fails the checks for
+	// publishing it.
+	ErrNotPublishable = errors.New("food cannot be published")
+	// ErrInvalidScope is returned for an unknown FoodScope.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// FoodScope narrows a food listing by whose foods it shows.
+type FoodScope string
+
+const (
+	// AllFoods lists the user's own foods and everyone's public ones.
+	AllFoods FoodScope = ""
+	// MyFoods lists only the user's own foods, public or not.
+	MyFoods FoodScope = "mine"
+	// PublicFoods lists only public foods, the user's own included.
+	PublicFoods FoodScope = "public"
+)
+
+// condition returns the WHERE condition selecting the foods in scope for
+// userID, on the foods alias f, and its arguments.
+func (scope FoodScope) condition(userID UserID) (string, []any, error) {
+	switch scope {
+	case AllFoods, "all":
+		return "(f.creator_id = ? OR f.public = true)", []any{userID}, nil
+	case MyFoods:
+		return "f.creator_id = ?", []any{userID}, nil
+	case PublicFoods:
+		return "f.public = true", nil, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+}
+
+const (
+	// publishCalorieSlack and publishCalorieTolerance bound how far a
+	// published food's calories may be from 4 kcal/g of protein and carbs
+	// and 9 kcal/g of fat: the larger of the slack in kcal and the
+	// tolerance as a fraction of the macro calories.
+	publishCalorieSlack     = 20
+	publishCalorieTolerance = 0.2
+)
+
+// SetFoodPublic publishes or unpublishes every version of id's family on
+// behalf of userID, who must have created it, and returns the current
+// version. Publishing checks the current version with checkPublishable.
+// Unpublishing hides the food from new users but leaves existing log entries
+// and recipes using it untouched.
+func (s *sqlStore) SetFoodPublic(ctx context.Context, userID UserID, id FoodID, public bool) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	f, err := s.getFood(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFoodAccess(f, userID, EditFood); err != nil {
+		return nil, err
+	}
+	currentID, err := s.latestVersion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if public {
+		current, err := newNutritionResolver(s).resolve(ctx, currentID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkPublishable(ctx, current); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, s.bind("UPDATE foods SET public = ? WHERE family_id = ?"), public, f.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("updating food visibility: %w", err)
+	}
+	return newNutritionResolver(s).resolve(ctx, currentID)
+}
+
+// checkPublishable makes sure a food is fit for other users: it has a name
+// and a measurement, its macros are filled in and add up to roughly its
+// calories, and, for recipes, every ingredient is public too.
+func (s *sqlStore) checkPublishable(ctx context.Context, f *Food) error {
+	if strings.TrimSpace(f.Name) == "" {
+		return fmt.Errorf("%w: name is empty", ErrNotPublishable)
+	}
+	if f.MeasurementAmount <= 0 || strings.TrimSpace(f.MeasurementUnit) == "" {
+		return fmt.Errorf("%w: measurement amount and unit are required", ErrNotPublishable)
+	}
+	if f.Calories < 0 || f.Protein < 0 || f.Carbs < 0 || f.Fat < 0 {
+		return fmt.Errorf("%w: calories and macros must not be negative", ErrNotPublishable)
+	}
+	if f.Calories == 0 && f.Protein == 0 && f.Carbs == 0 && f.Fat == 0 {
+		return fmt.Errorf("%w: calories and macros are missing", ErrNotPublishable)
+	}
+	expected := f.Protein*macroCalories["protein"] + f.Carbs*macroCalories["carbs"] + f.Fat*macroCalories["fat"]
+	if math.Abs(f.Calories-expected) > math.Max(publishCalorieSlack, publishCalorieTolerance*expected) {
+		return fmt.Errorf("%w: %.0f kcal does not match the macros (about %.0f kcal)", ErrNotPublishable, f.Calories, expected)
+	}
+
+	for _, item := range f.Ingredients {
+		ing, err := s.getFood(ctx, item.IngredientID)
+		if err != nil {
+			return err
+		}
+		if ing == nil || !ing.Public {
+			name := "an ingredient"
+			if ing != nil {
+				name = ing.Name
+			}
+			return fmt.Errorf("%w: %s is not public", ErrNotPublishable, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testPublishFoods(t *testing.T, s Store) {
+	owner := createTestUser(t, s)
+	other := createTestUser(t, s)
+	listNames := func(user *User, scope FoodScope) []string {
+		t.Helper()
+		page, err := s.GetFoods(t.Context(), user.ID, scope, PageOptions{})
+		if err != nil {
+			t.Fatalf("GetFoods(%q) failed: %v", scope, err)
+		}
+		var names []string
+		for _, f := range page.Items {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	// 100g: 100kcal, 10p, 10c, 2f, which adds up to 98kcal
+	oats := createTestIngredient(t, s, owner, "Oats")
+	bad, err := s.CreateFood(t.Context(), Food{CreatorID: owner.ID, Name: "Too Good", MeasurementUnit: "g", MeasurementAmount: 100, Calories: 500, Protein: 10, Carbs: 10, Fat: 2})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	blank, err := s.CreateFood(t.Context(), Food{CreatorID: owner.ID, Name: "Blank", MeasurementUnit: "g", MeasurementAmount: 100})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	porridge, err := s.CreateFood(t.Context(), Food{CreatorID: owner.ID, Name: "Porridge", MeasurementUnit: "g", MeasurementAmount: 100, Ingredients: []RecipeItems{{IngredientID: oats.ID, Amount: 100}}})
+	if err != nil {
+		t.Fatalf("CreateFood (recipe) failed: %v", err)
+	}
+
+	// 1. Implausible foods and recipes with private ingredients are rejected
+	for _, f := range []*Food{bad, blank, porridge} {
+		if _, err := s.SetFoodPublic(t.Context(), owner.ID, f.ID, true); !errors.Is(err, ErrNotPublishable) {
+			t.Errorf("Expected ErrNotPublishable for %s, got %v", f.Name, err)
+		}
+	}
+	if _, err := s.SetFoodPublic(t.Context(), other.ID, oats.ID, true); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound publishing another user's private food, got %v", err)
+	}
+
+	// 2. Publishing makes every version public and shows it to other users
+	v2, err := s.UpdateFood(t.Context(), oats.ID, Food{CreatorID: owner.ID, Name: "Rolled Oats", MeasurementUnit: "g", MeasurementAmount: 100, Calories: 100, Protein: 10, Carbs: 10, Fat: 2})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	published, err := s.SetFoodPublic(t.Context(), owner.ID, oats.ID, true)
+	if err != nil {
+		t.Fatalf("SetFoodPublic failed: %v", err)
+	}
+	if published.ID != v2.ID || !published.Public {
+		t.Errorf("Expected the current version to be returned public, got %+v", published)
+	}
+	versions, err := s.GetFoodVersions(t.Context(), owner.ID, v2.ID)
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	for _, v := range versions {
+		if !v.Public {
+			t.Errorf("Expected version %d to be public", v.Version)
+		}
+	}
+	if _, err := s.SetFoodPublic(t.Context(), other.ID, v2.ID, false); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden unpublishing another user's food, got %v", err)
+	}
+
+	// 3. Listings filter by scope
+	if names := listNames(other, AllFoods); len(names) != 1 || names[0] != "Rolled Oats" {
+		t.Errorf("Expected other to see Rolled Oats, got %v", names)
+	}
+	if names := listNames(other, MyFoods); len(names) != 0 {
+		t.Errorf("Expected other to have no foods, got %v", names)
+	}
+	if names := listNames(owner, PublicFoods); len(names) != 1 || names[0] != "Rolled Oats" {
+		t.Errorf("Expected only Rolled Oats to be public, got %v", names)
+	}
+	if names := listNames(owner, MyFoods); len(names) != 4 {
+		t.Errorf("Expected the owner's 4 foods, got %v", names)
+	}
+	if _, err := s.GetFoods(t.Context(), owner.ID, "friends", PageOptions{}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+	page, err := s.SearchFoods(t.Context(), owner.ID, "oats", MyFoods, PageOptions{})
+	if err != nil {
+		t.Fatalf("SearchFoods failed: %v", err)
+	}
+	if len(page.Items) != 1 {
+		t.Errorf("Expected to find the owner's oats, got %d foods", len(page.Items))
+	}
+
+	// 4. Updates keep the food public, and recipes of public foods publish
+	v3, err := s.UpdateFood(t.Context(), v2.ID, Food{CreatorID: owner.ID, Name: "Rolled Oats", MeasurementUnit: "g", MeasurementAmount: 100, Calories: 100, Protein: 10, Carbs: 10, Fat: 2})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	if !v3.Public {
+		t.Errorf("Expected the new version to stay public")
+	}
+	if _, err := s.SetFoodPublic(t.Context(), owner.ID, porridge.ID, true); err != nil {
+		t.Errorf("Expected the recipe to publish once its ingredient is public, got %v", err)
+	}
+
+	// 5. Unpublishing hides the food but leaves other users' logs intact
+	createTestLogEntry(t, s, other, v3, 200, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	if _, err := s.SetFoodPublic(t.Context(), owner.ID, v3.ID, false); err != nil {
+		t.Fatalf("SetFoodPublic (unpublish) failed: %v", err)
+	}
+	if names := listNames(other, AllFoods); len(names) != 1 || names[0] != "Porridge" {
+		t.Errorf("Expected only Porridge to remain visible, got %v", names)
+	}
+	if err := s.AuthorizeFood(t.Context(), other.ID, v3.ID, ViewFood); err != nil {
+		t.Errorf("Expected the logged version to stay viewable, got %v", err)
+	}
+	if err := s.AuthorizeFood(t.Context(), other.ID, v2.ID, ViewFood); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected an unlogged version to be hidden, got %v", err)
+	}
+	period, err := NewPeriod("day", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Monday)
+	if err != nil {
+		t.Fatalf("NewPeriod failed: %v", err)
+	}
+	stats, err := s.GetStats(t.Context(), other.ID, period)
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Calories != 200 {
+		t.Errorf("Expected the logged 200kcal to still count, got %v", stats.Calories)
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMaxRecipeDepth is used when Config.MaxRecipeDepth is zero.
+const DefaultMaxRecipeDepth = 8
+
+// RecipeCycleError is returned when a recipe would include itself, either
+// directly or through other recipes. Path names every food along the cycle.
+type RecipeCycleError struct {
+	Path []string
+}
+
+func (e *RecipeCycleError) Error() string {
+	return fmt.Sprintf("recipe includes itself: %s", strings.Join(e.Path, " -> "))
+}
+
+// RecipeDepthError is returned when recipes are nested more deeply than the
+// configured maximum. Path names the recipes along the deepest branch found.
+type RecipeDepthError struct {
+	Max  int
+	Path []string
+}
+
+func (e *RecipeDepthError) Error() string {
+	return fmt.Sprintf("recipes nested more than %d deep: %s", e.Max, strings.Join(e.Path, " -> "))
+}
+
+// checkIngredientGraph walks the ingredients of a food about to be written and
+// rejects cycles, excessive nesting and ingredients its creator cannot see,
+// which are reported as missing so their existence is not revealed. A recipe
+// may not include any version of its own family, since an update would
+// otherwise be able to reach back to itself.
+func (s *sqlStore) checkIngredientGraph(ctx context.Context, food *Food) error {
+	loaded := make(map[FoodID]*Food)
+	load := func(id FoodID) (*Food, error) {
+		if f, ok := loaded[id]; ok {
+			return f, nil
+		}
+		f, err := s.getFood(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		loaded[id] = f
+		return f, nil
+	}
+
+	var walk func(f *Food, path []*Food) error
+	walk = func(f *Food, path []*Food) error {
+		path = append(path, f)
+		if len(path) > s.maxRecipeDepth {
+			return &RecipeDepthError{Max: s.maxRecipeDepth, Path: foodNames(path)}
+		}
+		for _, item := range f.Ingredients {
+			ing, err := load(item.IngredientID)
+			if err != nil {
+				return err
+			}
+			if ing == nil {
+				return fmt.Errorf("%w: %s", ErrIngredientNotFound, uuid.UUID(item.IngredientID))
+			}
+			if len(path) == 1 {
+				ok, err := s.canUseIngredient(ctx, food, ing)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("%w: %s", ErrIngredientNotFound, uuid.UUID(item.IngredientID))
+				}
+			}
+			for _, seen := range path {
+				if seen.ID == ing.ID || seen.FamilyID == ing.FamilyID {
+					return &RecipeCycleError{Path: append(foodNames(path), ing.Name)}
+				}
+			}
+			if len(ing.Ingredients) > 0 {
+				if err := walk(ing, path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return walk(food, nil)
+}
+
+func foodNames(foods []*Food) []string {
+	names := make([]string, len(foods))
+	for i, f := range foods {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// canUseIngredient reports whether food's creator may use ing: it must be
+// visible to them, or from a family earlier versions of food already used, so
+// a recipe keeps working after an ingredient it was built with is
+// unpublished.
+func (s *sqlStore) canUseIngredient(ctx context.Context, food *Food, ing *Food) (bool, error) {
+	if ing.VisibleTo(food.CreatorID) {
+		return true, nil
+	}
+	query := `
+		SELECT COUNT(*)
+		FROM recipe_items ri
+		JOIN foods r ON r.id = ri.recipe_id
+		WHERE r.family_id = ? AND ri.family_id = ?
+	`
+	var n int
+	if err := s.db.QueryRowContext(ctx, s.bind(query), food.FamilyID, ing.FamilyID).Scan(&n); err != nil {
+		return false, fmt.Errorf("checking earlier ingredients: %w", err)
+	}
+	return n > 0, nil
+}
@@ -1,26 +1,30 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func createTestUser(t *testing.T) *User {
+func createTestUser(t *testing.T, s Store) *User {
 	u := User{
 		Name:      "Test User " + uuid.NewString(),
 		Email:     "test+" + uuid.NewString() + "@example.com",
 		CreatedAt: time.Now(),
 	}
-	created, err := CreateUser(u)
+	created, err := s.CreateUser(t.Context(), u)
 	if err != nil {
 		t.Fatalf("failed to create test user: %v", err)
 	}
 	return created
 }
 
-func createTestIngredient(t *testing.T, user *User, name string) *Food {
+func createTestIngredient(t *testing.T, s Store, user *User, name string) *Food {
 	id, _ := uuid.NewV7()
 	f := Food{
 		ID:                FoodID(id),
@@ -39,29 +43,18 @@ func createTestIngredient(t *testing.T, user *User, name string) *Food {
 		CreatedAt:         time.Now(),
 	}
 
-	_, err := db.Exec(`
-        INSERT INTO foods (
-            id, creator_id, family_id, version, is_current, name, type, 
-            calories, protein, carbs, fat, measurement_unit, measurement_amount, public, created_at
-        )
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `, f.ID, f.CreatorID, f.FamilyID, f.Version, f.IsCurrent, f.Name, f.Type,
-		f.Calories, f.Protein, f.Carbs, f.Fat, f.MeasurementUnit, f.MeasurementAmount, f.Public, f.CreatedAt)
+	created, err := s.CreateFood(t.Context(), f)
 	if err != nil {
 		t.Fatalf("failed to insert test ingredient: %v", err)
 	}
 
-	return &f
+	return created
 }
 
-func TestRecipeLifecycle(t *testing.T) {
-	if db == nil {
-		t.Skip("Database not initialized")
-	}
-
-	user := createTestUser(t)
-	flour := createTestIngredient(t, user, "Flour")
-	sugar := createTestIngredient(t, user, "Sugar")
+func testRecipeLifecycle(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	flour := createTestIngredient(t, s, user, "Flour")
+	sugar := createTestIngredient(t, s, user, "Sugar")
 
 	// 1. Create Recipe
 	recipe := Food{
@@ -73,7 +66,7 @@ func TestRecipeLifecycle(t *testing.T) {
 		},
 	}
 
-	created, err := CreateFood(recipe)
+	created, err := s.CreateFood(t.Context(), recipe)
 	if err != nil {
 		t.Fatalf("CreateFood failed: %v", err)
 	}
@@ -94,7 +87,7 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// 2. Get Recipe
-	fetched, err := GetFood(created.ID)
+	fetched, err := s.GetFood(t.Context(), created.ID)
 	if err != nil {
 		t.Fatalf("GetFood failed: %v", err)
 	}
@@ -118,10 +111,11 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// 3. List Recipes (GetFoods should return recipes now)
-	list, err := GetFoods(user.ID)
+	listPage, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods failed: %v", err)
 	}
+	list := listPage.Items
 	// We might have the ingredients created earlier in the list too, so check if our recipe is there.
 	foundRecipe := false
 	for _, f := range list {
@@ -140,7 +134,7 @@ func TestRecipeLifecycle(t *testing.T) {
 	created.Ingredients = []RecipeItems{
 		{IngredientID: flour.ID, Amount: 600},
 	}
-	updated, err := UpdateFood(created.ID, *created)
+	updated, err := s.UpdateFood(t.Context(), created.ID, *created)
 	if err != nil {
 		t.Fatalf("UpdateFood failed: %v", err)
 	}
@@ -158,7 +152,7 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// Verify old version is not current
-	old, err := GetFood(created.ID)
+	old, err := s.GetFood(t.Context(), created.ID)
 	if err != nil {
 		t.Fatalf("GetFood (old) failed: %v", err)
 	}
@@ -167,10 +161,11 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// Verify GetFoods only shows current
-	listV2, err := GetFoods(user.ID)
+	listV2Page, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods (v2) failed: %v", err)
 	}
+	listV2 := listV2Page.Items
 	foundUpdated := false
 	for _, f := range listV2 {
 		if f.ID == updated.ID {
@@ -186,7 +181,7 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// 5. Get Versions
-	versions, err := GetFoodVersions(updated.ID)
+	versions, err := s.GetFoodVersions(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("GetFoodVersions failed: %v", err)
 	}
@@ -201,16 +196,17 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// 6. Delete Recipe
-	err = DeleteFood(updated.ID)
+	err = s.DeleteFood(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("DeleteFood failed: %v", err)
 	}
 
 	// Verify deletion
-	listAfterDelete, err := GetFoods(user.ID)
+	listAfterDeletePage, err := s.GetFoods(t.Context(), user.ID, AllFoods, PageOptions{})
 	if err != nil {
 		t.Fatalf("GetFoods (after delete) failed: %v", err)
 	}
+	listAfterDelete := listAfterDeletePage.Items
 	for _, f := range listAfterDelete {
 		if f.ID == updated.ID {
 			t.Errorf("Deleted recipe found in list")
@@ -218,7 +214,7 @@ func TestRecipeLifecycle(t *testing.T) {
 	}
 
 	// Verify versions are all deleted
-	versionsAfterDelete, err := GetFoodVersions(updated.ID)
+	versionsAfterDelete, err := s.GetFoodVersions(t.Context(), user.ID, updated.ID)
 	if err != nil {
 		t.Fatalf("GetFoodVersions (after delete) failed: %v", err)
 	}
@@ -226,3 +222,414 @@ func TestRecipeLifecycle(t *testing.T) {
 		t.Errorf("Expected 0 versions after delete, got %d", len(versionsAfterDelete))
 	}
 }
+
+func testRecipeNutrition(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 100g: 100kcal, 10p, 10c, 2f
+	flour := createTestIngredient(t, s, user, "Flour")
+	flour.Nutrients = []FoodNutrient{{Name: "Iron", Amount: 4, Unit: "mg"}}
+	flour, err := s.UpdateFood(t.Context(), flour.ID, *flour)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	sugar := createTestIngredient(t, s, user, "Sugar")
+
+	// 1. Posted macros are replaced by the ingredient totals
+	dough, err := s.CreateFood(t.Context(), Food{
+		CreatorID:         user.ID,
+		Name:              "Dough",
+		Calories:          1,
+		MeasurementUnit:   "serving",
+		MeasurementAmount: 1,
+		Ingredients: []RecipeItems{
+			{IngredientID: flour.ID, Amount: 200},
+			{IngredientID: sugar.ID, Amount: 100},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (dough) failed: %v", err)
+	}
+	if dough.Calories != 300 || dough.Protein != 30 || dough.Carbs != 30 || dough.Fat != 6 {
+		t.Errorf("Unexpected dough macros: %+v", dough)
+	}
+
+	// 2. Nested recipes roll up recursively, including nutrients
+	cake, err := s.CreateFood(t.Context(), Food{
+		CreatorID:         user.ID,
+		Name:              "Cake",
+		MeasurementUnit:   "serving",
+		MeasurementAmount: 1,
+		Ingredients: []RecipeItems{
+			{IngredientID: dough.ID, Amount: 2},
+			{IngredientID: sugar.ID, Amount: 50},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (cake) failed: %v", err)
+	}
+
+	fetched, err := s.GetFood(t.Context(), cake.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if fetched.Calories != 650 {
+		t.Errorf("Expected 650 calories, got %f", fetched.Calories)
+	}
+	if len(fetched.Nutrients) != 1 || fetched.Nutrients[0].Name != "Iron" || fetched.Nutrients[0].Amount != 16 {
+		t.Errorf("Expected 16mg Iron, got %+v", fetched.Nutrients)
+	}
+
+	// 3. Stats use the rolled-up values
+	createTestLogEntry(t, s, user, cake, 0.5, time.Now())
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Now()))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Calories != 325 {
+		t.Errorf("Expected 325 calories, got %f", stats.Calories)
+	}
+
+	// 4. Unknown ingredients are rejected
+	_, err = s.CreateFood(t.Context(), Food{
+		CreatorID:   user.ID,
+		Name:        "Mystery",
+		Ingredients: []RecipeItems{{IngredientID: FoodID(uuid.New()), Amount: 1}},
+	})
+	if !errors.Is(err, ErrIngredientNotFound) {
+		t.Errorf("Expected ErrIngredientNotFound, got %v", err)
+	}
+}
+
+func testRecipeCycles(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	flour := createTestIngredient(t, s, user, "Flour")
+
+	newRecipe := func(name string, ingredient FoodID) *Food {
+		t.Helper()
+		f, err := s.CreateFood(t.Context(), Food{
+			CreatorID:         user.ID,
+			Name:              name,
+			MeasurementUnit:   "serving",
+			MeasurementAmount: 1,
+			Ingredients:       []RecipeItems{{IngredientID: ingredient, Amount: 1}},
+		})
+		if err != nil {
+			t.Fatalf("CreateFood (%s) failed: %v", name, err)
+		}
+		return f
+	}
+
+	// 1. Direct: a recipe cannot include an earlier version of itself
+	bread := newRecipe("Bread", flour.ID)
+	bread.Ingredients = []RecipeItems{{IngredientID: bread.ID, Amount: 1}}
+	_, err := s.UpdateFood(t.Context(), bread.ID, *bread)
+	var cycle *RecipeCycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected RecipeCycleError, got %v", err)
+	}
+
+	// 2. Indirect: Bread -> Sandwich -> Bread
+	sandwich := newRecipe("Sandwich", bread.ID)
+	bread.Ingredients = []RecipeItems{{IngredientID: sandwich.ID, Amount: 1}}
+	_, err = s.UpdateFood(t.Context(), bread.ID, *bread)
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected RecipeCycleError, got %v", err)
+	}
+	if got := strings.Join(cycle.Path, " -> "); got != "Bread -> Sandwich -> Bread" {
+		t.Errorf("Unexpected cycle path: %s", got)
+	}
+
+	// 3. Depth: DefaultMaxRecipeDepth levels are fine, one more is not
+	prev := flour.ID
+	for i := range DefaultMaxRecipeDepth {
+		prev = newRecipe(fmt.Sprintf("Level %d", i+1), prev).ID
+	}
+	_, err = s.CreateFood(t.Context(), Food{
+		CreatorID:   user.ID,
+		Name:        "Too Deep",
+		Ingredients: []RecipeItems{{IngredientID: prev, Amount: 1}},
+	})
+	var depth *RecipeDepthError
+	if !errors.As(err, &depth) {
+		t.Fatalf("Expected RecipeDepthError, got %v", err)
+	}
+	if depth.Max != DefaultMaxRecipeDepth {
+		t.Errorf("Expected max %d, got %d", DefaultMaxRecipeDepth, depth.Max)
+	}
+}
+
+func testRecipeFollowLatest(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	flour := createTestIngredient(t, s, user, "Flour") // 100kcal per 100g
+
+	newRecipe := func(name string, item RecipeItems) *Food {
+		t.Helper()
+		f, err := s.CreateFood(t.Context(), Food{
+			CreatorID:         user.ID,
+			Name:              name,
+			MeasurementUnit:   "serving",
+			MeasurementAmount: 1,
+			Ingredients:       []RecipeItems{item},
+		})
+		if err != nil {
+			t.Fatalf("CreateFood (%s) failed: %v", name, err)
+		}
+		return f
+	}
+	pinned := newRecipe("Pinned Bread", RecipeItems{IngredientID: flour.ID, Amount: 200})
+	following := newRecipe("Following Bread", RecipeItems{IngredientID: flour.ID, Amount: 200, FollowLatest: true})
+	toast := newRecipe("Toast", RecipeItems{IngredientID: pinned.ID, Amount: 1})
+
+	if pinned.Ingredients[0].FamilyID != flour.FamilyID {
+		t.Errorf("Recipe item should record the ingredient family")
+	}
+
+	// 1. Update the ingredient: only the follower sees it
+	flour.Calories = 200
+	flourV2, err := s.UpdateFood(t.Context(), flour.ID, *flour)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+
+	gotPinned, err := s.GetFood(t.Context(), pinned.ID)
+	if err != nil {
+		t.Fatalf("GetFood (pinned) failed: %v", err)
+	}
+	if gotPinned.Calories != 200 {
+		t.Errorf("Pinned recipe: expected 200 calories, got %f", gotPinned.Calories)
+	}
+	gotFollowing, err := s.GetFood(t.Context(), following.ID)
+	if err != nil {
+		t.Fatalf("GetFood (following) failed: %v", err)
+	}
+	if gotFollowing.Calories != 400 {
+		t.Errorf("Following recipe: expected 400 calories, got %f", gotFollowing.Calories)
+	}
+	if gotFollowing.Ingredients[0].IngredientID != flourV2.ID {
+		t.Errorf("Following recipe should report the current ingredient version")
+	}
+	listed, err := s.GetFoods(t.Context(), user.ID, MyFoods, PageOptions{})
+	if err != nil {
+		t.Fatalf("GetFoods failed: %v", err)
+	}
+	found, err := s.SearchFoods(t.Context(), user.ID, "following", MyFoods, PageOptions{})
+	if err != nil {
+		t.Fatalf("SearchFoods failed: %v", err)
+	}
+	for _, f := range append(listed.Items, found.Items...) {
+		if f.ID == following.ID && f.Calories != 400 {
+			t.Errorf("Following recipe: expected 400 calories when listed, got %f", f.Calories)
+		}
+	}
+	if len(found.Items) != 1 {
+		t.Errorf("Expected to find the following recipe, got %d results", len(found.Items))
+	}
+
+	// 2. Dependents
+	dependents, err := s.GetRecipeDependents(t.Context(), user.ID, flourV2.ID)
+	if err != nil {
+		t.Fatalf("GetRecipeDependents failed: %v", err)
+	}
+	if len(dependents) != 2 {
+		t.Fatalf("Expected 2 dependents, got %d", len(dependents))
+	}
+	for _, d := range dependents {
+		switch d.Recipe.ID {
+		case pinned.ID:
+			if !d.Outdated {
+				t.Errorf("Pinned recipe should be outdated")
+			}
+		case following.ID:
+			if d.Outdated || !d.FollowLatest {
+				t.Errorf("Following recipe should not be outdated")
+			}
+		default:
+			t.Errorf("Unexpected dependent %s", d.Recipe.Name)
+		}
+	}
+
+	// 3. Cascade creates new versions of the pinned recipe and its dependents
+	cascaded, err := s.CascadeFoodUpdate(t.Context(), user.ID, flourV2.ID)
+	if err != nil {
+		t.Fatalf("CascadeFoodUpdate failed: %v", err)
+	}
+	if len(cascaded) != 2 {
+		t.Fatalf("Expected 2 cascaded recipes, got %d", len(cascaded))
+	}
+	if cascaded[0].FamilyID != pinned.FamilyID || cascaded[0].Version != 2 || cascaded[0].Calories != 400 {
+		t.Errorf("Unexpected cascaded bread: %+v", cascaded[0])
+	}
+	if cascaded[1].FamilyID != toast.FamilyID || cascaded[1].Ingredients[0].IngredientID != cascaded[0].ID {
+		t.Errorf("Toast should now use the new bread version: %+v", cascaded[1])
+	}
+
+	dependents, err = s.GetRecipeDependents(t.Context(), user.ID, flourV2.ID)
+	if err != nil {
+		t.Fatalf("GetRecipeDependents (after cascade) failed: %v", err)
+	}
+	for _, d := range dependents {
+		if d.Outdated {
+			t.Errorf("%s still outdated after cascade", d.Recipe.Name)
+		}
+	}
+
+	// 4. A recipe pinning two versions of the ingredient is cascaded once
+	sugar := createTestIngredient(t, s, user, "Sugar")
+	sugarV2, err := s.UpdateFood(t.Context(), sugar.ID, *sugar)
+	if err != nil {
+		t.Fatalf("UpdateFood (sugar) failed: %v", err)
+	}
+	fudge, err := s.CreateFood(t.Context(), Food{
+		CreatorID: user.ID, Name: "Fudge", MeasurementUnit: "serving", MeasurementAmount: 1,
+		Ingredients: []RecipeItems{{IngredientID: sugar.ID, Amount: 100}, {IngredientID: sugarV2.ID, Amount: 50}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (fudge) failed: %v", err)
+	}
+	sugarV3, err := s.UpdateFood(t.Context(), sugarV2.ID, *sugarV2)
+	if err != nil {
+		t.Fatalf("UpdateFood (sugar) failed: %v", err)
+	}
+	cascaded, err = s.CascadeFoodUpdate(t.Context(), user.ID, sugarV3.ID)
+	if err != nil {
+		t.Fatalf("CascadeFoodUpdate (fudge) failed: %v", err)
+	}
+	if len(cascaded) != 1 || cascaded[0].FamilyID != fudge.FamilyID || cascaded[0].Version != 2 {
+		t.Fatalf("Expected one new fudge version, got %+v", cascaded)
+	}
+	if items := cascaded[0].Ingredients; len(items) != 1 || items[0].IngredientID != sugarV3.ID || items[0].Amount != 150 {
+		t.Errorf("Expected the sugar items merged into 150g of the new sugar, got %+v", items)
+	}
+
+	// 5. The ingredient cannot switch to a unit its followers cannot convert
+	scone := newRecipe("Scone", RecipeItems{IngredientID: flourV2.ID, Amount: 100, Unit: "g", FollowLatest: true})
+	flourV2.MeasurementUnit, flourV2.MeasurementAmount = "cup", 1
+	if _, err := s.UpdateFood(t.Context(), flourV2.ID, *flourV2); !errors.Is(err, ErrBreaksRecipes) {
+		t.Errorf("Expected ErrBreaksRecipes, got %v", err)
+	}
+	flourV2.Density = 0.5
+	if _, err := s.UpdateFood(t.Context(), flourV2.ID, *flourV2); err != nil {
+		t.Fatalf("UpdateFood (with density) failed: %v", err)
+	}
+	gotScone, err := s.GetFood(t.Context(), scone.ID)
+	if err != nil {
+		t.Fatalf("GetFood (scone) failed: %v", err)
+	}
+	if gotScone.Calories <= 0 {
+		t.Errorf("Expected the scone to measure its flour in cups, got %f calories", gotScone.Calories)
+	}
+}
+
+func testRecipeYield(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 100g: 100kcal, 10p, 10c, 2f
+	flour := createTestIngredient(t, s, user, "Flour")
+	sugar := createTestIngredient(t, s, user, "Sugar")
+
+	// 1. 2.5kg of ingredients cook down to 2kg and make 5 servings
+	stew, err := s.CreateFood(t.Context(), Food{
+		CreatorID:         user.ID,
+		Name:              "Stew",
+		MeasurementUnit:   "pot",
+		MeasurementAmount: 1,
+		YieldWeight:       2000,
+		Servings:          5,
+		Ingredients: []RecipeItems{
+			{IngredientID: flour.ID, Amount: 2000},
+			{IngredientID: sugar.ID, Amount: 500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+
+	fetched, err := s.GetFood(t.Context(), stew.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if fetched.YieldWeight != 2000 || fetched.Servings != 5 {
+		t.Errorf("Expected yield 2000g in 5 servings, got %fg in %f", fetched.YieldWeight, fetched.Servings)
+	}
+	if fetched.RawWeight != 2500 {
+		t.Errorf("Expected raw weight 2500, got %f", fetched.RawWeight)
+	}
+	if math.Abs(fetched.CookingLoss-0.2) > 1e-9 {
+		t.Errorf("Expected cooking loss 0.2, got %f", fetched.CookingLoss)
+	}
+	if fetched.PerServing == nil || fetched.PerServing.Calories != 500 || fetched.PerServing.Protein != 50 {
+		t.Errorf("Expected 500 kcal and 50g protein per serving, got %+v", fetched.PerServing)
+	}
+	if fetched.Per100g == nil || fetched.Per100g.Calories != 125 {
+		t.Errorf("Expected 125 kcal per 100g, got %+v", fetched.Per100g)
+	}
+	if stew.PerServing == nil || stew.PerServing.Calories != 500 {
+		t.Errorf("Expected CreateFood to return per serving nutrition, got %+v", stew.PerServing)
+	}
+
+	// 2. Servings and cooked grams convert to amounts of the recipe
+	amount, err := fetched.AmountForServings(2)
+	if err != nil {
+		t.Fatalf("AmountForServings failed: %v", err)
+	}
+	if amount != 0.4 {
+		t.Errorf("Expected 2 servings to be 0.4 pots, got %f", amount)
+	}
+	createTestLogEntry(t, s, user, fetched, amount, time.Now())
+
+	amount, err = fetched.AmountForCookedGrams(300)
+	if err != nil {
+		t.Fatalf("AmountForCookedGrams failed: %v", err)
+	}
+	if amount != 0.15 {
+		t.Errorf("Expected 300g to be 0.15 pots, got %f", amount)
+	}
+	createTestLogEntry(t, s, user, fetched, amount, time.Now())
+
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Now()))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Calories != 1375 {
+		t.Errorf("Expected 1375 calories, got %f", stats.Calories)
+	}
+
+	// 3. Recipes without a yield cannot be logged by servings or weight
+	dough, err := s.CreateFood(t.Context(), Food{
+		CreatorID:   user.ID,
+		Name:        "Dough",
+		Ingredients: []RecipeItems{{IngredientID: flour.ID, Amount: 100}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (dough) failed: %v", err)
+	}
+	if _, err := dough.AmountForServings(1); !errors.Is(err, ErrNoServings) {
+		t.Errorf("Expected ErrNoServings, got %v", err)
+	}
+	if _, err := dough.AmountForCookedGrams(100); !errors.Is(err, ErrNoYieldWeight) {
+		t.Errorf("Expected ErrNoYieldWeight, got %v", err)
+	}
+	if dough.PerServing != nil || dough.Per100g != nil || dough.CookingLoss != 0 {
+		t.Errorf("Expected no yield figures for dough, got %+v", dough)
+	}
+
+	// 4. Nested recipes are weighed by their yield
+	bowl, err := s.CreateFood(t.Context(), Food{
+		CreatorID:   user.ID,
+		Name:        "Bowl",
+		Ingredients: []RecipeItems{{IngredientID: stew.ID, Amount: 0.25}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (bowl) failed: %v", err)
+	}
+	if bowl.RawWeight != 500 {
+		t.Errorf("Expected bowl raw weight 500, got %f", bowl.RawWeight)
+	}
+
+	// 5. Negative yields are rejected
+	_, err = s.CreateFood(t.Context(), Food{CreatorID: user.ID, Name: "Bad", YieldWeight: -1})
+	if !errors.Is(err, ErrInvalidYield) {
+		t.Errorf("Expected ErrInvalidYield, got %v", err)
+	}
+}
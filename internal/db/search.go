@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchRecentWindow is how far back a logged food counts as recently logged
+// when ranking search results.
+const searchRecentWindow = 30 * 24 * time.Hour
+
+// SearchFoods returns the current foods in scope for userID matching q by name,
+// brand or nutrient. Every word of q matches as a prefix. Text relevance is
+// boosted for the user's own foods and foods they logged recently. Results
+// are only sorted by relevance, so opts.Sort must be empty or "relevance".
+// Recipe macros are rolled up from their ingredients, as GetFood reports them.
+func (s *sqlStore) SearchFoods(ctx context.Context, userID UserID, q string, scope FoodScope, opts PageOptions) (Page[Food], error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	visible, visibleArgs, err := scope.condition(userID)
+	if err != nil {
+		return Page[Food]{}, err
+	}
+
+	if opts.Sort != "" && opts.Sort != "relevance" {
+		return Page[Food]{}, invalidSort(opts.Sort)
+	}
+	terms := searchTerms(q)
+	key := searchKey(terms)
+	// Rankings have no stable key to resume from, so pages are offsets into
+	// the results of one query.
+	after, err := decodeCursor(opts.Cursor, "relevance")
+	if err != nil {
+		return Page[Food]{}, err
+	}
+	offset := 0
+	if after != nil {
+		if after.Query != key {
+			return Page[Food]{}, ErrInvalidCursor
+		}
+		offset = after.Offset
+	}
+
+	if len(terms) == 0 {
+		return Page[Food]{Items: []Food{}}, nil
+	}
+
+	query := `
+		WITH matches AS (` + s.dialect.searchMatches + `)
+		SELECT ` + foodColumns("f") + `
+		FROM matches m
+		JOIN foods f ON f.id = m.food_id
+		LEFT JOIN (
+			SELECT lf.family_id, COUNT(*) AS n
+			FROM food_log_entries le
+			JOIN foods lf ON lf.id = le.food_id
+			WHERE le.user_id = ? AND le.logged_at >= ? AND le.deleted_at IS NULL
+			GROUP BY lf.family_id
+		) recent ON recent.family_id = f.family_id
+		WHERE f.is_current = true AND f.deleted_at IS NULL AND ` + visible + `
+		ORDER BY m.relevance * (1
+			+ CASE WHEN f.creator_id = ? THEN 0.5 ELSE 0 END
+			+ CASE WHEN recent.n IS NULL THEN 0 ELSE 1 END) DESC, f.name, f.id
+		LIMIT ? OFFSET ?
+	`
+	since := time.Now().Add(-searchRecentWindow).UTC()
+	limit := opts.limit()
+	args := []any{s.dialect.searchQuery(terms), userID, since}
+	args = append(args, visibleArgs...)
+	args = append(args, userID, limit+1, offset)
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return Page[Food]{}, fmt.Errorf("searching foods: %w", err)
+	}
+	defer rows.Close()
+
+	var foods []Food
+	for rows.Next() {
+		var f Food
+		if err := scanFood(rows, &f); err != nil {
+			return Page[Food]{}, fmt.Errorf("scanning food: %w", err)
+		}
+		foods = append(foods, f)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[Food]{}, fmt.Errorf("searching foods: %w", err)
+	}
+	resolver := newNutritionResolver(s)
+	for i := range foods {
+		if err := resolver.resolveMacros(ctx, &foods[i]); err != nil {
+			return Page[Food]{}, err
+		}
+	}
+
+	return newPage(foods, limit, func(Food) cursor {
+		return cursor{Sort: "relevance", Offset: offset + limit, Query: key}
+	}), nil
+}
+
+// indexFood adds a new food version to the search index. Recipes are indexed
+// by the nutrients rolled up from their ingredients.
+func (s *sqlStore) indexFood(ctx context.Context, tx *sql.Tx, food Food) error {
+	names := make([]string, len(food.Nutrients))
+	for i, n := range food.Nutrients {
+		names[i] = n.Name
+	}
+	_, err := tx.ExecContext(ctx, s.bind("INSERT INTO food_search (food_id, name, brand, nutrients) VALUES (?, ?, ?, ?)"),
+		food.ID, food.Name, food.Brand, strings.Join(names, " "))
+	if err != nil {
+		return fmt.Errorf("indexing food: %w", err)
+	}
+	return nil
+}
+
+// searchTerms splits q into words, dropping punctuation so that user input
+// can never be read as query syntax.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchKey identifies a search by its terms, so that a cursor cannot be
+// used to page through the results of a different query.
+func searchKey(terms []string) string {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(strings.Join(terms, " "))))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// ftsQuery builds an SQLite FTS5 query matching every term as a prefix.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// tsQuery builds a PostgreSQL tsquery matching every term as a prefix.
+func tsQuery(terms []string) string {
+	prefixed := make([]string, len(terms))
+	for i, t := range terms {
+		prefixed[i] = t + ":*"
+	}
+	return strings.Join(prefixed, " & ")
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func createSearchFood(t *testing.T, s Store, user *User, name, brand string, public bool) *Food {
+	t.Helper()
+	f, err := s.CreateFood(t.Context(), Food{
+		CreatorID:         user.ID,
+		Name:              name,
+		Brand:             brand,
+		Public:            public,
+		MeasurementUnit:   "g",
+		MeasurementAmount: 100,
+		Calories:          100,
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (%s) failed: %v", name, err)
+	}
+	return f
+}
+
+func searchNames(t *testing.T, s Store, user *User, q string) []string {
+	t.Helper()
+	page, err := s.SearchFoods(t.Context(), user.ID, q, AllFoods, PageOptions{})
+	if err != nil {
+		t.Fatalf("SearchFoods(%q) failed: %v", q, err)
+	}
+	names := make([]string, len(page.Items))
+	for i, f := range page.Items {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func testSearchFoods(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	other := createTestUser(t, s)
+
+	breast := createSearchFood(t, s, user, "Chicken Breast", "Tyson", false)
+	soup := createSearchFood(t, s, other, "Chicken Soup", "", true)
+	createSearchFood(t, s, other, "Chicken Secret", "", false)
+	createSearchFood(t, s, other, "Beef Stew", "", true)
+	juice, err := s.CreateFood(t.Context(), Food{
+		CreatorID: other.ID,
+		Name:      "Orange Juice",
+		Public:    true,
+		Nutrients: []FoodNutrient{{Name: "Vitamin C", Amount: 50, Unit: "mg"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood (juice) failed: %v", err)
+	}
+
+	// 1. Prefix matches on name, own foods first, private foods hidden
+	names := searchNames(t, s, user, "chick")
+	if len(names) != 2 || names[0] != "Chicken Breast" || names[1] != "Chicken Soup" {
+		t.Errorf("Expected [Chicken Breast Chicken Soup], got %v", names)
+	}
+
+	// 2. Every word must match
+	if names := searchNames(t, s, user, "chi bre"); len(names) != 1 || names[0] != "Chicken Breast" {
+		t.Errorf("Expected [Chicken Breast], got %v", names)
+	}
+
+	// 3. Brands and nutrients are searchable
+	if names := searchNames(t, s, user, "tys"); len(names) != 1 || names[0] != "Chicken Breast" {
+		t.Errorf("Expected brand match [Chicken Breast], got %v", names)
+	}
+	if names := searchNames(t, s, user, "vitamin"); len(names) != 1 || names[0] != juice.Name {
+		t.Errorf("Expected nutrient match [Orange Juice], got %v", names)
+	}
+
+	// 4. Recently logged foods are boosted above the user's own
+	createTestLogEntry(t, s, user, soup, 100, time.Now())
+	names = searchNames(t, s, user, "chicken")
+	if len(names) != 2 || names[0] != "Chicken Soup" {
+		t.Errorf("Expected Chicken Soup first, got %v", names)
+	}
+
+	// 5. Only current versions match
+	breast.Name = "Chicken Thigh"
+	if _, err := s.UpdateFood(t.Context(), breast.ID, *breast); err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	if names := searchNames(t, s, user, "breast"); len(names) != 0 {
+		t.Errorf("Expected no match for the old name, got %v", names)
+	}
+	if names := searchNames(t, s, user, "thigh"); len(names) != 1 {
+		t.Errorf("Expected a match for the new name, got %v", names)
+	}
+
+	// 6. Deleted foods and query syntax are ignored
+	if err := s.DeleteFood(t.Context(), other.ID, soup.ID); err != nil {
+		t.Fatalf("DeleteFood failed: %v", err)
+	}
+	if names := searchNames(t, s, user, "soup"); len(names) != 0 {
+		t.Errorf("Expected deleted food to be hidden, got %v", names)
+	}
+	if names := searchNames(t, s, user, `" * : &`); len(names) != 0 {
+		t.Errorf("Expected no results for punctuation, got %v", names)
+	}
+	if names := searchNames(t, s, user, `beef" OR "chicken`); len(names) != 0 {
+		t.Errorf("Expected quotes to be treated as separators, got %v", names)
+	}
+}
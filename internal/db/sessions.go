@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -22,7 +23,10 @@ type Session struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
-func CreateSession(userID UserID) (*Session, error) {
+func (s *sqlStore) CreateSession(ctx context.Context, userID UserID) (*Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Create a new session ID (UUID)
 	sessionID := uuid.New().String()
 	now := time.Now()
@@ -30,7 +34,7 @@ func CreateSession(userID UserID) (*Session, error) {
 	expiresAt := now.Add(30 * 24 * time.Hour)
 
 	query := `INSERT INTO sessions (id, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`
-	_, err := db.Exec(query, sessionID, userID, now, expiresAt)
+	_, err := s.db.ExecContext(ctx, s.bind(query), sessionID, userID, now, expiresAt)
 	if err != nil {
 		return nil, fmt.Errorf("creating session: %w", err)
 	}
@@ -43,9 +47,12 @@ func CreateSession(userID UserID) (*Session, error) {
 	}, nil
 }
 
-func GetSession(sessionID string) (*Session, error) {
+func (s *sqlStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `SELECT id, user_id, created_at, expires_at FROM sessions WHERE id = ?`
-	row := db.QueryRow(query, sessionID)
+	row := s.db.QueryRowContext(ctx, s.bind(query), sessionID)
 
 	var session Session
 	err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt)
@@ -58,16 +65,19 @@ func GetSession(sessionID string) (*Session, error) {
 
 	// Check expiry
 	if time.Now().After(session.ExpiresAt) {
-		_ = DeleteSession(sessionID) // Clean up expired session
+		_ = s.DeleteSession(ctx, sessionID) // Clean up expired session
 		return nil, nil
 	}
 
 	return &session, nil
 }
 
-func DeleteSession(sessionID string) error {
+func (s *sqlStore) DeleteSession(ctx context.Context, sessionID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM sessions WHERE id = ?`
-	_, err := db.Exec(query, sessionID)
+	_, err := s.db.ExecContext(ctx, s.bind(query), sessionID)
 	if err != nil {
 		return fmt.Errorf("deleting session: %w", err)
 	}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+)
+
+func testSessionLifecycle(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 1. Create
+	session, err := s.CreateSession(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if session.UserID != user.ID {
+		t.Errorf("Session user mismatch")
+	}
+
+	// 2. Get
+	fetched, err := s.GetSession(t.Context(), session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if fetched == nil || fetched.UserID != user.ID {
+		t.Fatalf("GetSession returned wrong session")
+	}
+
+	// 3. Delete
+	if err := s.DeleteSession(t.Context(), session.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	fetched, err = s.GetSession(t.Context(), session.ID)
+	if err != nil {
+		t.Fatalf("GetSession (after delete) failed: %v", err)
+	}
+	if fetched != nil {
+		t.Errorf("Expected nil session after delete")
+	}
+}
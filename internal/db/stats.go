@@ -1,86 +1,342 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
+
+	"azule.info/calorize/internal/units"
 )
 
 type RangeStats struct {
-	Date     string  `json:"date"` // YYYY-MM-DD
+	Date     string  `json:"date"`     // YYYY-MM-DD, first day of the period
+	EndDate  string  `json:"end_date"` // YYYY-MM-DD, last day of the period
 	Calories float64 `json:"calories"`
 	Protein  float64 `json:"protein"`
 	Carbs    float64 `json:"carbs"`
 	Fat      float64 `json:"fat"`
+	// Nutrients are the micronutrient totals, sorted by name.
+	Nutrients []NutrientTotal `json:"nutrients"`
+	// Meals breaks the macros down by meal tag.
+	Meals []MealStats `json:"meals"`
+	// Goal is filled in by GetStats when the user has goals.
+	Goal *GoalProgress `json:"goal,omitempty"`
+}
+
+// MealStats is the nutrition logged under one meal tag over a period. Days
+// counts the days the meal was logged on and Average is the nutrition per
+// such day, e.g. the average breakfast.
+type MealStats struct {
+	MealTag  string    `json:"meal_tag"`
+	Calories float64   `json:"calories"`
+	Protein  float64   `json:"protein"`
+	Carbs    float64   `json:"carbs"`
+	Fat      float64   `json:"fat"`
+	Days     int       `json:"days"`
+	Average  Nutrition `json:"average"`
+}
+
+// mealOrder sorts the usual meals in the order they are eaten, ahead of any
+// other tags.
+var mealOrder = map[string]int{"breakfast": 1, "lunch": 2, "dinner": 3, "snack": 4}
+
+// NutrientTotal is the amount of one micronutrient logged over a period.
+type NutrientTotal struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+// MaxSeriesBuckets caps how many buckets GetStatsSeries accepts, which also
+// bounds the size of its query.
+const MaxSeriesBuckets = 400
+
+// GetStats totals userID's logged nutrition over period. The period's
+// boundaries are local midnights, so callers build it in the user's time zone
+// with NewPeriod or RangePeriod; they are converted to UTC for the query.
+//
+// If the user has goals, each day of the period up to today is judged
+// against the goal in effect on it. Periods longer than MaxSeriesBuckets
+// days are returned without goal progress.
+func (s *sqlStore) GetStats(ctx context.Context, userID UserID, period Period) (RangeStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	series, err := s.statsSeries(ctx, userID, []Period{period})
+	if err != nil {
+		return RangeStats{}, err
+	}
+	stats := series[0]
+
+	goals, err := s.getGoals(ctx, userID)
+	if err != nil {
+		return RangeStats{}, err
+	}
+	if len(goals) == 0 {
+		return stats, nil
+	}
+	days, err := period.Buckets("day", time.Monday)
+	if errors.Is(err, ErrInvalidPeriod) {
+		return stats, nil
+	} else if err != nil {
+		return RangeStats{}, err
+	}
+	daily, err := s.statsSeries(ctx, userID, days)
+	if err != nil {
+		return RangeStats{}, err
+	}
+	today := time.Now().In(period.Start.Location()).Format("2006-01-02")
+	stats.Goal = goalProgress(goals, stats, daily, today)
+	return stats, nil
 }
 
-func GetStats(userID UserID, period string, date time.Time) (RangeStats, error) {
-	// Calculate start and end times based on period
-	// We assume 'date' is in the user's timezone or meaningful to them.
-	// We'll treat it as UTC for DB comparison or rely on truncated strings if using SQLite functions.
-	//
-	// Recommended: Calculate range in Go, pass as parameters.
-	// Period: 'day', 'week', 'month'
-
-	var start, end time.Time
-
-	// Normalize date to start of day
-	y, m, d := date.Date()
-	todayStart := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
-
-	switch period {
-	case "day":
-		start = todayStart
-		end = start.AddDate(0, 0, 1)
-	case "week":
-		// Assume week starting Monday? Or Sunday. Let's say Monday.
-		weekday := int(todayStart.Weekday())
-		if weekday == 0 {
-			weekday = 7
-		}
-		start = todayStart.AddDate(0, 0, -weekday+1)
-		end = start.AddDate(0, 0, 7)
-	case "month":
-		start = time.Date(y, m, 1, 0, 0, 0, 0, date.Location())
-		end = start.AddDate(0, 1, 0) // Start of next month
-	default:
-		return RangeStats{}, fmt.Errorf("invalid period: %s", period)
+// GetStatsSeries totals userID's logged nutrition for each of buckets, which
+// must be consecutive, as built by Period.Buckets. Buckets without logs are
+// returned with zero totals.
+func (s *sqlStore) GetStatsSeries(ctx context.Context, userID UserID, buckets []Period) ([]RangeStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.statsSeries(ctx, userID, buckets)
+}
+
+func (s *sqlStore) statsSeries(ctx context.Context, userID UserID, buckets []Period) ([]RangeStats, error) {
+	if len(buckets) == 0 || len(buckets) > MaxSeriesBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrInvalidPeriod, len(buckets))
+	}
+	for i, b := range buckets {
+		if !b.Start.Before(b.End) {
+			return nil, fmt.Errorf("%w: empty range", ErrInvalidPeriod)
+		}
+		if i > 0 && !b.Start.Equal(buckets[i-1].End) {
+			return nil, fmt.Errorf("%w: buckets are not consecutive", ErrInvalidPeriod)
+		}
+	}
+	start, end := buckets[0].Start, buckets[len(buckets)-1].End
+
+	// Sum logged amounts per bucket, meal, food version and unit in SQL, then
+	// convert and scale each version's nutrition in Go so recipes are rolled
+	// up from their ingredients. Buckets are picked by comparing logged_at
+	// with each bucket's end, which keeps local day boundaries exact across
+	// time zones and daylight saving changes on every backend.
+	bucket := "0"
+	var args []any
+	if len(buckets) > 1 {
+		var b strings.Builder
+		b.WriteString("CASE")
+		for i, p := range buckets[:len(buckets)-1] {
+			fmt.Fprintf(&b, " WHEN le.logged_at < ? THEN %d", i)
+			args = append(args, p.End.UTC())
+		}
+		fmt.Fprintf(&b, " ELSE %d END", len(buckets)-1)
+		bucket = b.String()
 	}
+	args = append(args, userID, start.UTC(), end.UTC())
 
 	query := `
-		SELECT 
-			SUM((le.amount / CASE WHEN f.measurement_amount = 0 THEN 1 ELSE f.measurement_amount END) * f.calories) as calories,
-			SUM((le.amount / CASE WHEN f.measurement_amount = 0 THEN 1 ELSE f.measurement_amount END) * f.protein) as protein,
-			SUM((le.amount / CASE WHEN f.measurement_amount = 0 THEN 1 ELSE f.measurement_amount END) * f.carbs) as carbs,
-			SUM((le.amount / CASE WHEN f.measurement_amount = 0 THEN 1 ELSE f.measurement_amount END) * f.fat) as fat
+		SELECT ` + bucket + ` AS bucket, le.meal_tag, le.food_id, le.unit, SUM(le.amount)
 		FROM food_log_entries le
-		JOIN foods f ON le.food_id = f.id
 		WHERE le.user_id = ? AND le.logged_at >= ? AND le.logged_at < ?
 		AND le.deleted_at IS NULL
+		GROUP BY bucket, le.meal_tag, le.food_id, le.unit
 	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying stats: %w", err)
+	}
+	defer rows.Close()
 
-	row := db.QueryRow(query, userID, start, end)
+	type loggedAmount struct {
+		bucket  int
+		mealTag string
+		foodID  FoodID
+		unit    string
+		amount  float64
+	}
+	var amounts []loggedAmount
+	for rows.Next() {
+		var a loggedAmount
+		if err := rows.Scan(&a.bucket, &a.mealTag, &a.foodID, &a.unit, &a.amount); err != nil {
+			return nil, fmt.Errorf("scanning stats: %w", err)
+		}
+		amounts = append(amounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning stats: %w", err)
+	}
+	rows.Close()
 
-	var s RangeStats
-	// SQLite SUM returns NULL if no rows, scan might fail if not nullable pointers.
-	// Use sql.NullFloat64 or pointers.
-	var cal, prot, carb, fat *float64
-	if err := row.Scan(&cal, &prot, &carb, &fat); err != nil {
-		return RangeStats{}, fmt.Errorf("scanning stats: %w", err)
+	totals := make([]Food, len(buckets))
+	meals := make([]map[string]*Food, len(buckets))
+	for i := range meals {
+		meals[i] = make(map[string]*Food)
+	}
+	resolver := newNutritionResolver(s)
+	for _, a := range amounts {
+		f, err := resolver.resolve(ctx, a.foodID)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		amount, err := f.measure(a.amount, a.unit)
+		if err != nil {
+			return nil, err
+		}
+		totals[a.bucket].addScaled(f, servingsOf(f, amount))
+		meal, ok := meals[a.bucket][a.mealTag]
+		if !ok {
+			meal = &Food{}
+			meals[a.bucket][a.mealTag] = meal
+		}
+		meal.addScaled(f, servingsOf(f, amount))
 	}
 
-	if cal != nil {
-		s.Calories = *cal
+	days, err := s.mealDays(ctx, userID, buckets)
+	if err != nil {
+		return nil, err
 	}
-	if prot != nil {
-		s.Protein = *prot
+
+	series := make([]RangeStats, len(buckets))
+	for i, b := range buckets {
+		series[i] = RangeStats{
+			Date:     b.Start.Format("2006-01-02"),
+			EndDate:  b.End.AddDate(0, 0, -1).Format("2006-01-02"),
+			Calories: totals[i].Calories,
+			Protein:  totals[i].Protein,
+			Carbs:    totals[i].Carbs,
+			Fat:      totals[i].Fat,
+
+			Nutrients: nutrientTotals(totals[i].Nutrients),
+			Meals:     mealStats(meals[i], days[i]),
+		}
 	}
-	if carb != nil {
-		s.Carbs = *carb
+	return series, nil
+}
+
+// nutrientTotals merges nutrients whose names differ only in case and whose
+// units convert into each other, such as mg and µg of the same vitamin. Each
+// total uses the smallest unit seen for it, so no precision is lost to tiny
+// fractions. Nutrients in unconvertible units, like IU, stay separate.
+func nutrientTotals(nutrients []FoodNutrient) []NutrientTotal {
+	totals := []NutrientTotal{}
+	for _, n := range nutrients {
+		merged := false
+		for i := range totals {
+			t := &totals[i]
+			if !strings.EqualFold(t.Name, n.Name) {
+				continue
+			}
+			amount, err := units.Convert(n.Amount, n.Unit, t.Unit, 0)
+			if err != nil {
+				continue
+			}
+			if scale, _ := units.Convert(1, t.Unit, n.Unit, 0); scale > 1 {
+				t.Amount, t.Unit = t.Amount*scale, n.Unit
+				amount = n.Amount
+			}
+			t.Amount += amount
+			merged = true
+			break
+		}
+		if !merged {
+			totals = append(totals, NutrientTotal{Name: n.Name, Amount: n.Amount, Unit: n.Unit})
+		}
 	}
-	if fat != nil {
-		s.Fat = *fat
+	sort.Slice(totals, func(i, j int) bool {
+		if a, b := strings.ToLower(totals[i].Name), strings.ToLower(totals[j].Name); a != b {
+			return a < b
+		}
+		return totals[i].Unit < totals[j].Unit
+	})
+	return totals
+}
+
+// mealDays counts, for each bucket, the distinct local days each meal tag was
+// logged on. Days are taken in the location of the buckets.
+func (s *sqlStore) mealDays(ctx context.Context, userID UserID, buckets []Period) ([]map[string]int, error) {
+	start, end := buckets[0].Start, buckets[len(buckets)-1].End
+	loc := start.Location()
+
+	query := `
+		SELECT meal_tag, logged_at
+		FROM food_log_entries
+		WHERE user_id = ? AND logged_at >= ? AND logged_at < ?
+		AND deleted_at IS NULL
+	`
+	rows, err := s.db.QueryContext(ctx, s.bind(query), userID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying meal days: %w", err)
 	}
-	s.Date = start.Format("2006-01-02") // Just label with start date
+	defer rows.Close()
 
-	return s, nil
+	type mealDay struct {
+		meal string
+		day  string
+	}
+	seen := make([]map[mealDay]bool, len(buckets))
+	counts := make([]map[string]int, len(buckets))
+	for i := range buckets {
+		seen[i] = make(map[mealDay]bool)
+		counts[i] = make(map[string]int)
+	}
+	for rows.Next() {
+		var meal string
+		var loggedAt time.Time
+		if err := rows.Scan(&meal, &loggedAt); err != nil {
+			return nil, fmt.Errorf("scanning meal days: %w", err)
+		}
+		i := sort.Search(len(buckets), func(i int) bool { return loggedAt.Before(buckets[i].End) })
+		if i == len(buckets) {
+			continue
+		}
+		key := mealDay{meal, loggedAt.In(loc).Format("2006-01-02")}
+		if !seen[i][key] {
+			seen[i][key] = true
+			counts[i][meal]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanning meal days: %w", err)
+	}
+	return counts, nil
+}
+
+// mealStats turns per-meal totals into MealStats, averaged over the days
+// each meal was logged on and sorted by mealOrder, then tag.
+func mealStats(meals map[string]*Food, days map[string]int) []MealStats {
+	stats := []MealStats{}
+	for tag, total := range meals {
+		m := MealStats{
+			MealTag:  tag,
+			Calories: total.Calories,
+			Protein:  total.Protein,
+			Carbs:    total.Carbs,
+			Fat:      total.Fat,
+			Days:     days[tag],
+		}
+		if m.Days > 0 {
+			n := float64(m.Days)
+			m.Average = Nutrition{Calories: m.Calories / n, Protein: m.Protein / n, Carbs: m.Carbs / n, Fat: m.Fat / n}
+		}
+		stats = append(stats, m)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := mealOrder[stats[i].MealTag], mealOrder[stats[j].MealTag]
+		if a == 0 {
+			a = len(mealOrder) + 1
+		}
+		if b == 0 {
+			b = len(mealOrder) + 1
+		}
+		if a != b {
+			return a < b
+		}
+		return stats[i].MealTag < stats[j].MealTag
+	})
+	return stats
 }
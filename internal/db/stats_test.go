@@ -1,11 +1,14 @@
 package db
 
 import (
+	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 )
 
-func createTestLogEntry(t *testing.T, user *User, food *Food, amount float64, logTime time.Time) *FoodLogEntry {
+func createTestLogEntry(t *testing.T, s Store, user *User, food *Food, amount float64, logTime time.Time) *FoodLogEntry {
 	// CreateFoodLogEntry helper might assume functional db setup.
 	// Since we haven't implemented CreateFoodLogEntry manually in this session but seeing it referenced in context,
 	// I'll assume it exists or use manual insert if checks fail.
@@ -18,29 +21,35 @@ func createTestLogEntry(t *testing.T, user *User, food *Food, amount float64, lo
 		MealTag:  "breakfast",
 		LoggedAt: logTime,
 	}
-	created, err := CreateFoodLogEntry(entry)
+	created, err := s.CreateFoodLogEntry(t.Context(), entry)
 	if err != nil {
 		t.Fatalf("CreateFoodLogEntry failed: %v", err)
 	}
 	return created
 }
 
-func TestGetStats(t *testing.T) {
-	if db == nil {
-		t.Skip("Database not initialized")
+// mustPeriod returns the named period containing date, with Monday weeks.
+func mustPeriod(t *testing.T, name string, date time.Time) Period {
+	t.Helper()
+	p, err := NewPeriod(name, date, time.Monday)
+	if err != nil {
+		t.Fatalf("NewPeriod(%q) failed: %v", name, err)
 	}
+	return p
+}
 
-	user := createTestUser(t)
+func testGetStats(t *testing.T, s Store) {
+	user := createTestUser(t, s)
 
 	// Food: 100kcal per 100g
-	food := createTestIngredient(t, user, "Test Food")
+	food := createTestIngredient(t, s, user, "Test Food")
 	// Update with specific macros
 	food.Calories = 100
 	food.Protein = 10
 	food.Carbs = 20
 	food.Fat = 5
 	food.MeasurementAmount = 100
-	updatedFood, err := UpdateFood(food.ID, *food)
+	updatedFood, err := s.UpdateFood(t.Context(), food.ID, *food)
 	if err != nil {
 		t.Fatalf("UpdateFood failed: %v", err)
 	}
@@ -48,15 +57,15 @@ func TestGetStats(t *testing.T) {
 	today := time.Now()
 
 	// Log 1: 200g (200kcal, 20p, 40c, 10f)
-	createTestLogEntry(t, user, updatedFood, 200, today)
+	createTestLogEntry(t, s, user, updatedFood, 200, today)
 
 	// Log 2: 50g (50kcal, 5p, 10c, 2.5f)
-	createTestLogEntry(t, user, updatedFood, 50, today)
+	createTestLogEntry(t, s, user, updatedFood, 50, today)
 
 	// Total: 250kcal, 25p, 50c, 12.5f
 
 	// Verify Daily Stats
-	res, err := GetStats(user.ID, "day", today)
+	res, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", today))
 	if err != nil {
 		t.Fatalf("GetStats failed: %v", err)
 	}
@@ -78,7 +87,7 @@ func TestGetStats(t *testing.T) {
 
 	// Verify Empty Stats (Yesterday)
 	yesterday := today.AddDate(0, 0, -1)
-	resEmpty, err := GetStats(user.ID, "day", yesterday)
+	resEmpty, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", yesterday))
 	if err != nil {
 		t.Fatalf("GetStats(yesterday) failed: %v", err)
 	}
@@ -86,9 +95,331 @@ func TestGetStats(t *testing.T) {
 	if statsEmpty.Calories != 0 {
 		t.Errorf("Expected 0 calories for yesterday, got %f", statsEmpty.Calories)
 	}
+
+	// Verify the day follows the date's time zone: a 100g dinner at 03:00 UTC
+	// counts towards the previous day in Los Angeles
+	la, err := LoadTimeZone("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadTimeZone failed: %v", err)
+	}
+	createTestLogEntry(t, s, user, updatedFood, 100, time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC))
+	resLA, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Date(2024, 3, 9, 0, 0, 0, 0, la)))
+	if err != nil {
+		t.Fatalf("GetStats (Los Angeles) failed: %v", err)
+	}
+	if resLA.Calories != 100 || resLA.Date != "2024-03-09" {
+		t.Errorf("Expected 100 calories on 2024-03-09, got %f on %s", resLA.Calories, resLA.Date)
+	}
+	resUTC, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("GetStats (UTC) failed: %v", err)
+	}
+	if resUTC.Calories != 0 {
+		t.Errorf("Expected 0 calories on 2024-03-09 UTC, got %f", resUTC.Calories)
+	}
 }
 
 // Re-using createTestUser/Ingredient from recipe test package context if allowed,
 // otherwise need to duplicate or assume shared package.
 // They are in the same package `db`, so it should share test helpers if in same directory?
 // Yes, `go test ./internal/db` compiles all test files in package together.
+
+func testStatsPeriods(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Test Food")
+
+	// 1. Log distinct amounts (100kcal per 100g) around Saturday 2024-03-09
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 12, 0, 0, 0, time.UTC) }
+	createTestLogEntry(t, s, user, food, 10, day(2024, 3, 3)) // Sunday
+	createTestLogEntry(t, s, user, food, 20, day(2024, 3, 4)) // Monday
+	createTestLogEntry(t, s, user, food, 40, day(2024, 3, 9)) // Saturday
+	createTestLogEntry(t, s, user, food, 80, day(2024, 3, 10))
+	createTestLogEntry(t, s, user, food, 160, day(2024, 1, 15))
+	createTestLogEntry(t, s, user, food, 320, day(2023, 12, 31))
+
+	saturday := day(2024, 3, 9)
+	cases := []struct {
+		name      string
+		weekStart time.Weekday
+		date      time.Time
+		calories  float64
+		from, to  string
+	}{
+		{"week", time.Monday, saturday, 140, "2024-03-04", "2024-03-10"},
+		{"week", time.Sunday, saturday, 70, "2024-03-03", "2024-03-09"},
+		{"month", time.Monday, saturday, 150, "2024-03-01", "2024-03-31"},
+		{"year", time.Monday, saturday, 310, "2024-01-01", "2024-12-31"},
+		{"last_7_days", time.Monday, saturday, 70, "2024-03-03", "2024-03-09"},
+		{"last_7_days", time.Monday, day(2024, 3, 10), 140, "2024-03-04", "2024-03-10"},
+		{"last_30_days", time.Monday, saturday, 70, "2024-02-09", "2024-03-09"},
+	}
+
+	// 2. Calendar periods and rolling windows
+	for _, c := range cases {
+		p, err := NewPeriod(c.name, c.date, c.weekStart)
+		if err != nil {
+			t.Fatalf("NewPeriod(%q) failed: %v", c.name, err)
+		}
+		stats, err := s.GetStats(t.Context(), user.ID, p)
+		if err != nil {
+			t.Fatalf("GetStats(%q) failed: %v", c.name, err)
+		}
+		if stats.Calories != c.calories || stats.Date != c.from || stats.EndDate != c.to {
+			t.Errorf("%s from %s: expected %f calories over %s..%s, got %f over %s..%s",
+				c.name, c.weekStart, c.calories, c.from, c.to, stats.Calories, stats.Date, stats.EndDate)
+		}
+	}
+
+	// 3. Custom ranges include both ends
+	p, err := RangePeriod(day(2023, 12, 31), day(2024, 1, 15))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	stats, err := s.GetStats(t.Context(), user.ID, p)
+	if err != nil {
+		t.Fatalf("GetStats (range) failed: %v", err)
+	}
+	if stats.Calories != 480 {
+		t.Errorf("Expected 480 calories over the range, got %f", stats.Calories)
+	}
+
+	// 4. Unknown periods and reversed ranges are rejected
+	if _, err := NewPeriod("fortnight", saturday, time.Monday); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod for fortnight, got %v", err)
+	}
+	if _, err := NewPeriod("last_0_days", saturday, time.Monday); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod for last_0_days, got %v", err)
+	}
+	if _, err := RangePeriod(day(2024, 1, 15), day(2023, 12, 31)); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod for a reversed range, got %v", err)
+	}
+}
+
+func testStatsSeries(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Test Food")
+
+	// 1. Log around the first week of March 2024 (100kcal per 100g)
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+	createTestLogEntry(t, s, user, food, 10, day(3, 3))
+	createTestLogEntry(t, s, user, food, 20, day(3, 4))
+	createTestLogEntry(t, s, user, food, 40, day(3, 9))
+	createTestLogEntry(t, s, user, food, 80, day(3, 9))
+	createTestLogEntry(t, s, user, food, 160, day(1, 20))
+
+	series := func(from, to time.Time, bucket string) []RangeStats {
+		t.Helper()
+		p, err := RangePeriod(from, to)
+		if err != nil {
+			t.Fatalf("RangePeriod failed: %v", err)
+		}
+		buckets, err := p.Buckets(bucket, time.Monday)
+		if err != nil {
+			t.Fatalf("Buckets(%q) failed: %v", bucket, err)
+		}
+		res, err := s.GetStatsSeries(t.Context(), user.ID, buckets)
+		if err != nil {
+			t.Fatalf("GetStatsSeries(%q) failed: %v", bucket, err)
+		}
+		return res
+	}
+
+	// 2. Daily buckets include the empty days
+	daily := series(day(3, 1), day(3, 10), "day")
+	if len(daily) != 10 {
+		t.Fatalf("Expected 10 daily buckets, got %d", len(daily))
+	}
+	want := map[string]float64{"2024-03-03": 10, "2024-03-04": 20, "2024-03-09": 120}
+	for _, d := range daily {
+		if d.Date != d.EndDate {
+			t.Errorf("Expected a one-day bucket, got %s..%s", d.Date, d.EndDate)
+		}
+		if d.Calories != want[d.Date] {
+			t.Errorf("Expected %f calories on %s, got %f", want[d.Date], d.Date, d.Calories)
+		}
+	}
+
+	// 3. Weekly buckets are clipped to the range
+	weekly := series(day(3, 1), day(3, 10), "week")
+	if len(weekly) != 2 {
+		t.Fatalf("Expected 2 weekly buckets, got %d", len(weekly))
+	}
+	if weekly[0].Date != "2024-03-01" || weekly[0].EndDate != "2024-03-03" || weekly[0].Calories != 10 {
+		t.Errorf("Unexpected first week: %+v", weekly[0])
+	}
+	if weekly[1].Date != "2024-03-04" || weekly[1].EndDate != "2024-03-10" || weekly[1].Calories != 140 {
+		t.Errorf("Unexpected second week: %+v", weekly[1])
+	}
+
+	// 4. Monthly buckets
+	monthly := series(day(1, 15), day(3, 10), "month")
+	if len(monthly) != 3 {
+		t.Fatalf("Expected 3 monthly buckets, got %d", len(monthly))
+	}
+	if monthly[0].Calories != 160 || monthly[1].Calories != 0 || monthly[2].Calories != 150 {
+		t.Errorf("Unexpected monthly totals: %+v", monthly)
+	}
+	if monthly[1].Date != "2024-02-01" || monthly[1].EndDate != "2024-02-29" {
+		t.Errorf("Unexpected February bucket: %s..%s", monthly[1].Date, monthly[1].EndDate)
+	}
+
+	// 5. Local days survive a daylight saving change: 06:30 UTC on the 11th
+	// is still the 10th in Los Angeles, whose day is 23 hours long
+	la, err := LoadTimeZone("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadTimeZone failed: %v", err)
+	}
+	createTestLogEntry(t, s, user, food, 5, time.Date(2024, 3, 11, 6, 30, 0, 0, time.UTC))
+	local := series(time.Date(2024, 3, 10, 0, 0, 0, 0, la), time.Date(2024, 3, 11, 0, 0, 0, 0, la), "day")
+	if len(local) != 2 || local[0].Calories != 5 || local[1].Calories != 0 {
+		t.Errorf("Expected the late log on 2024-03-10 in Los Angeles, got %+v", local)
+	}
+
+	// 6. Unknown buckets and oversized series are rejected
+	p, err := RangePeriod(day(1, 1), day(12, 31))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	if _, err := p.Buckets("hour", time.Monday); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod for hour buckets, got %v", err)
+	}
+	long, err := RangePeriod(day(1, 1), day(1, 1).AddDate(2, 0, 0))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	if _, err := long.Buckets("day", time.Monday); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod for two years of days, got %v", err)
+	}
+}
+
+func testStatsNutrients(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+
+	// 1. Two foods store Vitamin C in different units and names' cases
+	orange := createTestIngredient(t, s, user, "Orange")
+	orange.Nutrients = []FoodNutrient{
+		{Name: "Vitamin C", Amount: 50, Unit: "mg"},
+		{Name: "Vitamin D", Amount: 40, Unit: "IU"},
+	}
+	orange, err := s.UpdateFood(t.Context(), orange.ID, *orange)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	pepper := createTestIngredient(t, s, user, "Pepper")
+	pepper.Nutrients = []FoodNutrient{
+		{Name: "vitamin c", Amount: 80000, Unit: "µg"},
+		{Name: "Vitamin D", Amount: 1, Unit: "mcg"},
+	}
+	pepper, err = s.UpdateFood(t.Context(), pepper.ID, *pepper)
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+
+	// 2. 200g of orange and 50g of pepper
+	now := time.Now()
+	createTestLogEntry(t, s, user, orange, 200, now)
+	createTestLogEntry(t, s, user, pepper, 50, now)
+
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", now))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+
+	// 3. Vitamin C merges into µg: 100mg + 40000µg = 140000µg; Vitamin D
+	// stays split between IU and µg
+	want := []NutrientTotal{
+		{Name: "Vitamin C", Amount: 140000, Unit: "µg"},
+		{Name: "Vitamin D", Amount: 80, Unit: "IU"},
+		{Name: "Vitamin D", Amount: 0.5, Unit: "mcg"},
+	}
+	if len(stats.Nutrients) != len(want) {
+		t.Fatalf("Expected %d nutrient totals, got %+v", len(want), stats.Nutrients)
+	}
+	for i, w := range want {
+		got := stats.Nutrients[i]
+		if !strings.EqualFold(got.Name, w.Name) || got.Unit != w.Unit || math.Abs(got.Amount-w.Amount) > 1e-6 {
+			t.Errorf("Expected %+v, got %+v", w, got)
+		}
+	}
+
+	// 4. Days without logs have no nutrients
+	empty, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "day", now.AddDate(0, 0, -1)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if empty.Nutrients == nil || len(empty.Nutrients) != 0 {
+		t.Errorf("Expected an empty nutrient list, got %+v", empty.Nutrients)
+	}
+}
+
+func testStatsMeals(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	food := createTestIngredient(t, s, user, "Test Food")
+
+	logMeal := func(tag string, amount float64, loggedAt time.Time) {
+		t.Helper()
+		if _, err := s.CreateFoodLogEntry(t.Context(), FoodLogEntry{UserID: user.ID, FoodID: food.ID, Amount: amount, MealTag: tag, LoggedAt: loggedAt}); err != nil {
+			t.Fatalf("CreateFoodLogEntry failed: %v", err)
+		}
+	}
+
+	// 1. Breakfast on two days (twice on the second), lunch and elevenses once
+	day := func(d, hour int) time.Time { return time.Date(2024, 3, d, hour, 0, 0, 0, time.UTC) }
+	logMeal("breakfast", 100, day(1, 8))
+	logMeal("breakfast", 100, day(2, 8))
+	logMeal("breakfast", 50, day(2, 9))
+	logMeal("lunch", 200, day(1, 12))
+	logMeal("elevenses", 10, day(2, 11))
+
+	stats, err := s.GetStats(t.Context(), user.ID, mustPeriod(t, "month", day(1, 0)))
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.Calories != 460 {
+		t.Errorf("Expected 460 calories, got %f", stats.Calories)
+	}
+
+	// 2. Meals are ordered breakfast, lunch, then other tags
+	if len(stats.Meals) != 3 {
+		t.Fatalf("Expected 3 meals, got %+v", stats.Meals)
+	}
+	want := []struct {
+		tag      string
+		calories float64
+		days     int
+		average  float64
+	}{
+		{"breakfast", 250, 2, 125},
+		{"lunch", 200, 1, 200},
+		{"elevenses", 10, 1, 10},
+	}
+	for i, w := range want {
+		m := stats.Meals[i]
+		if m.MealTag != w.tag || m.Calories != w.calories || m.Days != w.days || m.Average.Calories != w.average {
+			t.Errorf("Expected %s with %f calories over %d days (%f average), got %+v", w.tag, w.calories, w.days, w.average, m)
+		}
+	}
+	if stats.Meals[0].Protein != 25 || stats.Meals[0].Average.Protein != 12.5 {
+		t.Errorf("Expected 25g breakfast protein (12.5g average), got %+v", stats.Meals[0])
+	}
+
+	// 3. Series buckets carry their own breakdown
+	p, err := RangePeriod(day(1, 0), day(2, 0))
+	if err != nil {
+		t.Fatalf("RangePeriod failed: %v", err)
+	}
+	buckets, err := p.Buckets("day", time.Monday)
+	if err != nil {
+		t.Fatalf("Buckets failed: %v", err)
+	}
+	series, err := s.GetStatsSeries(t.Context(), user.ID, buckets)
+	if err != nil {
+		t.Fatalf("GetStatsSeries failed: %v", err)
+	}
+	if len(series[0].Meals) != 2 || len(series[1].Meals) != 2 {
+		t.Fatalf("Expected 2 meals on each day, got %+v and %+v", series[0].Meals, series[1].Meals)
+	}
+	if series[1].Meals[0].MealTag != "breakfast" || series[1].Meals[0].Calories != 150 || series[1].Meals[0].Days != 1 {
+		t.Errorf("Unexpected second day breakfast: %+v", series[1].Meals[0])
+	}
+}
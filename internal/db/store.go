@@ -0,0 +1,94 @@
+package db
+
+import "context"
+
+// FoodStore manages versioned foods and recipes.
+type FoodStore interface {
+	GetFoods(ctx context.Context, userID UserID, scope FoodScope, opts PageOptions) (Page[Food], error)
+	SearchFoods(ctx context.Context, userID UserID, q string, scope FoodScope, opts PageOptions) (Page[Food], error)
+	GetFood(ctx context.Context, id FoodID) (*Food, error)
+	GetFoodVersions(ctx context.Context, userID UserID, id FoodID) ([]Food, error)
+	GetFoodVersion(ctx context.Context, id FoodID, version int) (*Food, error)
+	DiffFoodVersions(ctx context.Context, userID UserID, id FoodID, from, to int) (*FoodDiff, error)
+	RestoreFoodVersion(ctx context.Context, userID UserID, id FoodID, version int) (*Food, error)
+	CreateFood(ctx context.Context, food Food) (*Food, error)
+	UpdateFood(ctx context.Context, id FoodID, food Food) (*Food, error)
+	DeleteFood(ctx context.Context, userID UserID, id FoodID) error
+	AuthorizeFood(ctx context.Context, userID UserID, id FoodID, access FoodAccess) error
+	ForkFood(ctx context.Context, userID UserID, id FoodID) (*Food, error)
+	SetFoodPublic(ctx context.Context, userID UserID, id FoodID, public bool) (*Food, error)
+
+	GetRecipeDependents(ctx context.Context, userID UserID, id FoodID) ([]RecipeDependent, error)
+	CascadeFoodUpdate(ctx context.Context, userID UserID, id FoodID) ([]Food, error)
+
+	GetFoodPortions(ctx context.Context, foodID FoodID) ([]FoodPortion, error)
+	CreateFoodPortion(ctx context.Context, portion FoodPortion) (*FoodPortion, error)
+	UpdateFoodPortion(ctx context.Context, portion FoodPortion) (*FoodPortion, error)
+	DeleteFoodPortion(ctx context.Context, foodID FoodID, id FoodPortionID) error
+}
+
+// LogStore manages a user's food log.
+type LogStore interface {
+	GetFoodLogEntries(ctx context.Context, userID UserID, filter LogFilter, opts PageOptions) (Page[FoodLogEntry], error)
+	CreateFoodLogEntry(ctx context.Context, entry FoodLogEntry) (*FoodLogEntry, error)
+	UpdateFoodLogEntry(ctx context.Context, entry FoodLogEntry) (*FoodLogEntry, error)
+	DeleteFoodLogEntry(ctx context.Context, id FoodLogEntryID, userID UserID) error
+}
+
+// UserStore manages users and their WebAuthn credentials.
+type UserStore interface {
+	GetUser(ctx context.Context, userName string) (*User, error)
+	GetUserByID(ctx context.Context, id UserID) (*User, error)
+	CreateUser(ctx context.Context, user User) (*User, error)
+	UpdateUser(ctx context.Context, user User) (*User, error)
+	DeleteUser(ctx context.Context, user User) error
+
+	AddUserCredential(ctx context.Context, user User, auth UserCredential) error
+	RemoveUserCredential(ctx context.Context, user User, auth UserCredential) error
+	GetUserCredentials(ctx context.Context, user User) ([]UserCredential, error)
+	SetCredentialLastUsed(ctx context.Context, user User, auth UserCredential) error
+}
+
+// SessionStore manages login sessions.
+type SessionStore interface {
+	CreateSession(ctx context.Context, userID UserID) (*Session, error)
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	DeleteSession(ctx context.Context, sessionID string) error
+}
+
+// StatsStore aggregates logged nutrition.
+type StatsStore interface {
+	GetStats(ctx context.Context, userID UserID, period Period) (RangeStats, error)
+	GetStatsSeries(ctx context.Context, userID UserID, buckets []Period) ([]RangeStats, error)
+	GetEnergyEstimate(ctx context.Context, userID UserID, period Period) (EnergyEstimate, error)
+}
+
+// GoalStore manages a user's nutrition goals.
+type GoalStore interface {
+	GetGoals(ctx context.Context, userID UserID) ([]Goal, error)
+	SetGoal(ctx context.Context, goal Goal) (*Goal, error)
+	DeleteGoal(ctx context.Context, userID UserID, id GoalID) error
+}
+
+// MeasurementStore manages a user's body measurements.
+type MeasurementStore interface {
+	GetMeasurements(ctx context.Context, userID UserID, filter MeasurementFilter) ([]Measurement, error)
+	GetMeasurementTrends(ctx context.Context, userID UserID, kind string, period Period) ([]MeasurementTrend, error)
+	CreateMeasurement(ctx context.Context, m Measurement) (*Measurement, error)
+	UpdateMeasurement(ctx context.Context, m Measurement) (*Measurement, error)
+	DeleteMeasurement(ctx context.Context, userID UserID, id MeasurementID) error
+}
+
+// Store is everything the API needs from a backend. Open returns the
+// SQLite or PostgreSQL implementation depending on the configured driver.
+type Store interface {
+	FoodStore
+	LogStore
+	UserStore
+	SessionStore
+	StatsStore
+	GoalStore
+	MeasurementStore
+
+	Close() error
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -11,12 +12,15 @@ import (
 )
 
 // Bare user functions
-func GetUser(userName string) (*User, error) {
-	query := `SELECT id, name, email, disabled_at, created_at FROM users WHERE name = ?`
-	row := db.QueryRow(query, userName)
+func (s *sqlStore) GetUser(ctx context.Context, userName string) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, name, email, disabled_at, created_at, timezone, week_start FROM users WHERE name = ?`
+	row := s.db.QueryRowContext(ctx, s.bind(query), userName)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.DisabledAt, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.DisabledAt, &user.CreatedAt, &user.TimeZone, &user.WeekStart)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Return nil if user not found, typical pattern or could return error
@@ -26,12 +30,15 @@ func GetUser(userName string) (*User, error) {
 	return &user, nil
 }
 
-func GetUserByID(id UserID) (*User, error) {
-	query := `SELECT id, name, email, disabled_at, created_at FROM users WHERE id = ?`
-	row := db.QueryRow(query, id)
+func (s *sqlStore) GetUserByID(ctx context.Context, id UserID) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, name, email, disabled_at, created_at, timezone, week_start FROM users WHERE id = ?`
+	row := s.db.QueryRowContext(ctx, s.bind(query), id)
 
 	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.DisabledAt, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.DisabledAt, &user.CreatedAt, &user.TimeZone, &user.WeekStart)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -41,7 +48,10 @@ func GetUserByID(id UserID) (*User, error) {
 	return &user, nil
 }
 
-func CreateUser(user User) (*User, error) {
+func (s *sqlStore) CreateUser(ctx context.Context, user User) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	if user.ID == UserID(uuid.Nil) {
 		newID, err := uuid.NewV7()
 		if err != nil {
@@ -52,9 +62,12 @@ func CreateUser(user User) (*User, error) {
 	if user.CreatedAt.IsZero() {
 		user.CreatedAt = time.Now()
 	}
+	if err := user.checkCalendar(); err != nil {
+		return nil, err
+	}
 
-	query := `INSERT INTO users (id, name, email, disabled_at, created_at) VALUES (?, ?, ?, ?, ?)`
-	_, err := db.Exec(query, user.ID, user.Name, user.Email, user.DisabledAt, user.CreatedAt)
+	query := `INSERT INTO users (id, name, email, disabled_at, created_at, timezone, week_start) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, s.bind(query), user.ID, user.Name, user.Email, user.DisabledAt, user.CreatedAt, user.TimeZone, user.WeekStart)
 	if err != nil {
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
@@ -62,18 +75,28 @@ func CreateUser(user User) (*User, error) {
 	return &user, nil
 }
 
-func UpdateUser(user User) (*User, error) {
-	query := `UPDATE users SET name = ?, email = ?, disabled_at = ? WHERE id = ?`
-	_, err := db.Exec(query, user.Name, user.Email, user.DisabledAt, user.ID)
+func (s *sqlStore) UpdateUser(ctx context.Context, user User) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := user.checkCalendar(); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE users SET name = ?, email = ?, disabled_at = ?, timezone = ?, week_start = ? WHERE id = ?`
+	_, err := s.db.ExecContext(ctx, s.bind(query), user.Name, user.Email, user.DisabledAt, user.TimeZone, user.WeekStart, user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("updating user: %w", err)
 	}
 	return &user, nil
 }
 
-func DeleteUser(user User) error {
+func (s *sqlStore) DeleteUser(ctx context.Context, user User) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM users WHERE id = ?`
-	_, err := db.Exec(query, user.ID)
+	_, err := s.db.ExecContext(ctx, s.bind(query), user.ID)
 	if err != nil {
 		return fmt.Errorf("deleting user: %w", err)
 	}
@@ -81,7 +104,10 @@ func DeleteUser(user User) error {
 }
 
 // User Auth functions
-func AddUserCredential(user User, auth UserCredential) error {
+func (s *sqlStore) AddUserCredential(ctx context.Context, user User, auth UserCredential) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	if len(auth.ID) == 0 {
 		id, err := uuid.NewV7()
 		if err != nil {
@@ -105,7 +131,7 @@ func AddUserCredential(user User, auth UserCredential) error {
 		id, user_id, name, public_key, attestation_type, aaguid, sign_count, transports, backup_eligible, backup_state, created_at, last_used_at
 	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = db.Exec(query,
+	_, err = s.db.ExecContext(ctx, s.bind(query),
 		auth.ID,
 		user.ID,
 		auth.Name,
@@ -125,18 +151,24 @@ func AddUserCredential(user User, auth UserCredential) error {
 	return nil
 }
 
-func RemoveUserCredential(user User, auth UserCredential) error {
+func (s *sqlStore) RemoveUserCredential(ctx context.Context, user User, auth UserCredential) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM user_credentials WHERE id = ? AND user_id = ?`
-	_, err := db.Exec(query, auth.ID, user.ID)
+	_, err := s.db.ExecContext(ctx, s.bind(query), auth.ID, user.ID)
 	if err != nil {
 		return fmt.Errorf("removing user credential: %w", err)
 	}
 	return nil
 }
 
-func GetUserCredentials(user User) ([]UserCredential, error) {
+func (s *sqlStore) GetUserCredentials(ctx context.Context, user User) ([]UserCredential, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `SELECT id, user_id, name, public_key, attestation_type, aaguid, sign_count, transports, backup_eligible, backup_state, created_at, last_used_at FROM user_credentials WHERE user_id = ?`
-	rows, err := db.Query(query, user.ID)
+	rows, err := s.db.QueryContext(ctx, s.bind(query), user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("getting user credentials: %w", err)
 	}
@@ -177,9 +209,12 @@ func GetUserCredentials(user User) ([]UserCredential, error) {
 	return credentials, nil
 }
 
-func SetCredentialLastUsed(user User, auth UserCredential) error {
+func (s *sqlStore) SetCredentialLastUsed(ctx context.Context, user User, auth UserCredential) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE user_credentials SET last_used_at = ? WHERE id = ? AND user_id = ?`
-	_, err := db.Exec(query, time.Now(), auth.ID, user.ID)
+	_, err := s.db.ExecContext(ctx, s.bind(query), time.Now(), auth.ID, user.ID)
 	if err != nil {
 		return fmt.Errorf("setting credential last used: %w", err)
 	}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testUserLifecycle(t *testing.T, s Store) {
+	// 1. Create
+	user := createTestUser(t, s)
+
+	// 2. Lookup by name and id
+	byName, err := s.GetUser(t.Context(), user.Name)
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if byName == nil || byName.ID != user.ID {
+		t.Fatalf("GetUser returned wrong user")
+	}
+	byID, err := s.GetUserByID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if byID == nil || byID.Email != user.Email {
+		t.Fatalf("GetUserByID returned wrong user")
+	}
+
+	missing, err := s.GetUser(t.Context(), "nobody-"+user.Name)
+	if err != nil {
+		t.Fatalf("GetUser (missing) failed: %v", err)
+	}
+	if missing != nil {
+		t.Errorf("Expected nil for missing user")
+	}
+
+	// 3. Update
+	now := time.Now()
+	user.Name = user.Name + " (disabled)"
+	user.DisabledAt = &now
+	user.TimeZone = "America/Los_Angeles"
+	user.WeekStart = "sunday"
+	if _, err := s.UpdateUser(t.Context(), *user); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	byID, err = s.GetUserByID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID (after update) failed: %v", err)
+	}
+	if byID.Name != user.Name || byID.DisabledAt == nil || byID.TimeZone != "America/Los_Angeles" {
+		t.Errorf("Update not reflected: %+v", byID)
+	}
+	if byID.Location().String() != "America/Los_Angeles" {
+		t.Errorf("Expected America/Los_Angeles location, got %s", byID.Location())
+	}
+	if byID.FirstWeekday() != time.Sunday {
+		t.Errorf("Expected weeks to start on Sunday, got %s", byID.FirstWeekday())
+	}
+
+	bad := *user
+	bad.TimeZone = "Mars/Olympus_Mons"
+	if _, err := s.UpdateUser(t.Context(), bad); !errors.Is(err, ErrInvalidTimeZone) {
+		t.Errorf("Expected ErrInvalidTimeZone, got %v", err)
+	}
+	bad = *user
+	bad.WeekStart = "funday"
+	if _, err := s.UpdateUser(t.Context(), bad); !errors.Is(err, ErrInvalidWeekStart) {
+		t.Errorf("Expected ErrInvalidWeekStart, got %v", err)
+	}
+
+	// 4. Credentials
+	cred := UserCredential{
+		ID:              UserCredentialID("credential-1"),
+		Name:            "Passkey",
+		PublicKey:       []byte("public-key"),
+		AttestationType: "none",
+		AAGUID:          "00000000-0000-0000-0000-000000000000",
+		SignCount:       3,
+		Transports:      []string{"internal", "hybrid"},
+		BackupEligible:  true,
+	}
+	if err := s.AddUserCredential(t.Context(), *user, cred); err != nil {
+		t.Fatalf("AddUserCredential failed: %v", err)
+	}
+	creds, err := s.GetUserCredentials(t.Context(), *user)
+	if err != nil {
+		t.Fatalf("GetUserCredentials failed: %v", err)
+	}
+	if len(creds) != 1 {
+		t.Fatalf("Expected 1 credential, got %d", len(creds))
+	}
+	if string(creds[0].ID) != "credential-1" || creds[0].SignCount != 3 || !creds[0].BackupEligible {
+		t.Errorf("Credential mismatch: %+v", creds[0])
+	}
+	if len(creds[0].Transports) != 2 {
+		t.Errorf("Expected 2 transports, got %d", len(creds[0].Transports))
+	}
+	if err := s.SetCredentialLastUsed(t.Context(), *user, cred); err != nil {
+		t.Fatalf("SetCredentialLastUsed failed: %v", err)
+	}
+	if err := s.RemoveUserCredential(t.Context(), *user, cred); err != nil {
+		t.Fatalf("RemoveUserCredential failed: %v", err)
+	}
+	creds, err = s.GetUserCredentials(t.Context(), *user)
+	if err != nil {
+		t.Fatalf("GetUserCredentials (after remove) failed: %v", err)
+	}
+	if len(creds) != 0 {
+		t.Errorf("Expected 0 credentials, got %d", len(creds))
+	}
+
+	// 5. Delete
+	if err := s.DeleteUser(t.Context(), *user); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	byID, err = s.GetUserByID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID (after delete) failed: %v", err)
+	}
+	if byID != nil {
+		t.Errorf("Expected nil after delete")
+	}
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"azule.info/calorize/internal/units"
+)
+
+// ErrVersionNotFound is returned when a food family has no live version with
+// the requested number.
+var ErrVersionNotFound = errors.New("food version not found")
+
+// FoodDiff lists what changed between two versions of a food family. Each
+// list is empty when that part of the food is unchanged.
+type FoodDiff struct {
+	From        int                `json:"from"`
+	To          int                `json:"to"`
+	Fields      []FieldChange      `json:"fields"`
+	Nutrients   []NutrientChange   `json:"nutrients"`
+	Ingredients []IngredientChange `json:"ingredients"`
+}
+
+// FieldChange is a food field, by its JSON name, with its old and new value.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// NutrientChange is a micronutrient that was added, removed or changed. From
+// is nil for added nutrients and To for removed ones; both are in Unit.
+type NutrientChange struct {
+	Name string   `json:"name"`
+	Unit string   `json:"unit"`
+	From *float64 `json:"from"`
+	To   *float64 `json:"to"`
+}
+
+// IngredientChange is a recipe ingredient, matched by family, that was added,
+// removed or changed in version, amount, unit or follow_latest. From is nil
+// for added ingredients and To for removed ones.
+type IngredientChange struct {
+	FamilyID FoodFamilyID `json:"family_id"`
+	Name     string       `json:"name"`
+	From     *RecipeItems `json:"from"`
+	To       *RecipeItems `json:"to"`
+}
+
+// GetFoodVersion returns version of the family id belongs to as stored, or
+// nil if the family has no such live version.
+func (s *sqlStore) GetFoodVersion(ctx context.Context, id FoodID, version int) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getFoodVersion(ctx, id, version)
+}
+
+// getFoodVersion loads version of the family id belongs to as stored, or nil
+// if the family has no such live version.
+func (s *sqlStore) getFoodVersion(ctx context.Context, id FoodID, version int) (*Food, error) {
+	query := `
+		SELECT v.id
+		FROM foods f
+		JOIN foods v ON v.family_id = f.family_id
+		WHERE f.id = ? AND v.version = ? AND v.deleted_at IS NULL
+	`
+	var versionID FoodID
+	err := s.db.QueryRowContext(ctx, s.bind(query), id, version).Scan(&versionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("finding food version: %w", err)
+	}
+	return s.getFood(ctx, versionID)
+}
+
+// DiffFoodVersions compares versions from and to of the family id belongs to,
+// as they were stored. A to of zero or less means the current version and a
+// from of zero or less the version before to. userID must be able to view
+// both versions; ones they cannot see are reported as ErrFoodNotFound.
+func (s *sqlStore) DiffFoodVersions(ctx context.Context, userID UserID, id FoodID, from, to int) (*FoodDiff, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if to <= 0 {
+		currentID, err := s.latestVersion(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		current, err := s.getFood(ctx, currentID)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.DeletedAt != nil {
+			return nil, ErrFoodNotFound
+		}
+		to = current.Version
+	}
+	if from <= 0 {
+		from = to - 1
+	}
+
+	a, err := s.getFoodVersion(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.getFoodVersion(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, from)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, to)
+	}
+	for _, v := range []*Food{a, b} {
+		if err := s.checkFoodView(ctx, v, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	ingredients, err := s.ingredientChanges(ctx, a.Ingredients, b.Ingredients)
+	if err != nil {
+		return nil, err
+	}
+	return &FoodDiff{
+		From:        from,
+		To:          to,
+		Fields:      fieldChanges(a, b),
+		Nutrients:   nutrientChanges(a.Nutrients, b.Nutrients),
+		Ingredients: ingredients,
+	}, nil
+}
+
+// fieldChanges compares the stored fields a user edits.
+func fieldChanges(a, b *Food) []FieldChange {
+	changes := []FieldChange{}
+	text := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, FieldChange{field, from, to})
+		}
+	}
+	number := func(field string, from, to float64) {
+		if !sameAmount(from, to) {
+			changes = append(changes, FieldChange{field, from, to})
+		}
+	}
+	text("name", a.Name, b.Name)
+	text("brand", a.Brand, b.Brand)
+	text("type", a.Type, b.Type)
+	number("calories", a.Calories, b.Calories)
+	number("protein", a.Protein, b.Protein)
+	number("carbs", a.Carbs, b.Carbs)
+	number("fat", a.Fat, b.Fat)
+	text("measurement_unit", a.MeasurementUnit, b.MeasurementUnit)
+	number("measurement_amount", a.MeasurementAmount, b.MeasurementAmount)
+	number("yield_weight", a.YieldWeight, b.YieldWeight)
+	number("servings", a.Servings, b.Servings)
+	number("density", a.Density, b.Density)
+	return changes
+}
+
+// nutrientChanges matches nutrients by name, ignoring case, and reports
+// amounts in the newer version's unit. A nutrient whose unit no longer
+// converts, such as mg to IU, shows up as removed and added.
+func nutrientChanges(from, to []FoodNutrient) []NutrientChange {
+	changes := []NutrientChange{}
+	matched := make([]bool, len(from))
+	for _, n := range to {
+		change := NutrientChange{Name: n.Name, Unit: n.Unit, To: &n.Amount}
+		for i, old := range from {
+			if matched[i] || !strings.EqualFold(old.Name, n.Name) {
+				continue
+			}
+			amount, err := units.Convert(old.Amount, old.Unit, n.Unit, 0)
+			if err != nil {
+				continue
+			}
+			matched[i] = true
+			change.From = &amount
+			break
+		}
+		if change.From == nil || !sameAmount(*change.From, n.Amount) {
+			changes = append(changes, change)
+		}
+	}
+	for i, old := range from {
+		if !matched[i] {
+			changes = append(changes, NutrientChange{Name: old.Name, Unit: old.Unit, From: &old.Amount})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.ToLower(changes[i].Name) < strings.ToLower(changes[j].Name)
+	})
+	return changes
+}
+
+// ingredientChanges matches recipe items by ingredient family and names each
+// change after the newer ingredient version involved.
+func (s *sqlStore) ingredientChanges(ctx context.Context, from, to []RecipeItems) ([]IngredientChange, error) {
+	var changes []IngredientChange
+	matched := make([]bool, len(from))
+	for _, item := range to {
+		change := IngredientChange{FamilyID: item.FamilyID, To: &item}
+		for i, old := range from {
+			if !matched[i] && old.FamilyID == item.FamilyID {
+				matched[i] = true
+				change.From = &old
+				break
+			}
+		}
+		if f := change.From; f != nil && f.IngredientID == item.IngredientID && f.FollowLatest == item.FollowLatest &&
+			f.Unit == item.Unit && sameAmount(f.Amount, item.Amount) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for i, old := range from {
+		if !matched[i] {
+			changes = append(changes, IngredientChange{FamilyID: old.FamilyID, From: &old})
+		}
+	}
+
+	for i := range changes {
+		item := changes[i].To
+		if item == nil {
+			item = changes[i].From
+		}
+		ing, err := s.getFood(ctx, item.IngredientID)
+		if err != nil {
+			return nil, err
+		}
+		if ing != nil {
+			changes[i].Name = ing.Name
+		}
+	}
+	if changes == nil {
+		changes = []IngredientChange{}
+	}
+	return changes, nil
+}
+
+// sameAmount reports whether two stored amounts are equal, allowing for the
+// rounding of unit conversions.
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// RestoreFoodVersion makes a copy of version of the family id belongs to, with
+// its nutrients, ingredients and portions, the family's new current version.
+// userID must have created the food. Visibility and fork provenance stay as
+// they are, since they belong to the family.
+func (s *sqlStore) RestoreFoodVersion(ctx context.Context, userID UserID, id FoodID, version int) (*Food, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	f, err := s.getFood(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFoodAccess(f, userID, EditFood); err != nil {
+		return nil, err
+	}
+	old, err := s.getFoodVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+	}
+	currentID, err := s.latestVersion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := *old
+	restored.CreatorID = userID
+	restored.CreatedAt = time.Time{}
+	if restored.Portions == nil {
+		// An empty list drops the current portions instead of carrying them
+		// forward, so the copy is exact.
+		restored.Portions = []FoodPortion{}
+	}
+	for i := range restored.Portions {
+		restored.Portions[i].CreatedAt = time.Time{}
+	}
+	return s.UpdateFood(ctx, currentID, restored)
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testFoodVersions(t *testing.T, s Store) {
+	owner := createTestUser(t, s)
+	other := createTestUser(t, s)
+
+	v1, err := s.CreateFood(t.Context(), Food{
+		CreatorID: owner.ID, Name: "Yogurt", MeasurementUnit: "g", MeasurementAmount: 100,
+		Calories: 60, Protein: 5, Carbs: 4, Fat: 3,
+		Nutrients: []FoodNutrient{{Name: "Calcium", Amount: 120, Unit: "mg"}, {Name: "Vitamin D", Amount: 1, Unit: "µg"}},
+		Portions:  []FoodPortion{{Name: "1 pot", Amount: 150, Unit: "g"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFood failed: %v", err)
+	}
+	v2, err := s.UpdateFood(t.Context(), v1.ID, Food{
+		CreatorID: owner.ID, Name: "Greek Yogurt", MeasurementUnit: "g", MeasurementAmount: 100,
+		Calories: 100, Protein: 10, Carbs: 4, Fat: 3,
+		Nutrients: []FoodNutrient{{Name: "Calcium", Amount: 0.11, Unit: "g"}, {Name: "Iron", Amount: 1, Unit: "mg"}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	v3, err := s.UpdateFood(t.Context(), v2.ID, Food{
+		CreatorID: owner.ID, Name: "Greek Yogurt", Brand: "Acme", MeasurementUnit: "g", MeasurementAmount: 100,
+		Calories: 100, Protein: 10, Carbs: 4, Fat: 3,
+		Nutrients: []FoodNutrient{{Name: "Calcium", Amount: 110, Unit: "mg"}, {Name: "Iron", Amount: 1, Unit: "mg"}},
+		Portions:  []FoodPortion{},
+	})
+	if err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+
+	// 1. Fields and nutrients that changed are reported, nutrients in the newer unit
+	diff, err := s.DiffFoodVersions(t.Context(), owner.ID, v3.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffFoodVersions failed: %v", err)
+	}
+	var fields []string
+	for _, c := range diff.Fields {
+		fields = append(fields, c.Field)
+	}
+	if len(fields) != 3 || fields[0] != "name" || fields[1] != "calories" || fields[2] != "protein" {
+		t.Errorf("Expected name, calories and protein to change, got %v", fields)
+	}
+	if len(diff.Nutrients) != 3 {
+		t.Fatalf("Expected 3 nutrient changes, got %+v", diff.Nutrients)
+	}
+	calcium, iron, vitaminD := diff.Nutrients[0], diff.Nutrients[1], diff.Nutrients[2]
+	if calcium.Name != "Calcium" || calcium.Unit != "g" || calcium.From == nil || !sameAmount(*calcium.From, 0.12) || *calcium.To != 0.11 {
+		t.Errorf("Expected calcium to go from 0.12g to 0.11g, got %+v", calcium)
+	}
+	if iron.Name != "Iron" || iron.From != nil || iron.To == nil {
+		t.Errorf("Expected iron to be added, got %+v", iron)
+	}
+	if vitaminD.Name != "Vitamin D" || vitaminD.From == nil || vitaminD.To != nil {
+		t.Errorf("Expected vitamin D to be removed, got %+v", vitaminD)
+	}
+
+	// 2. By default the current version is compared with the one before it
+	diff, err = s.DiffFoodVersions(t.Context(), owner.ID, v1.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("DiffFoodVersions (default) failed: %v", err)
+	}
+	if diff.From != 2 || diff.To != 3 {
+		t.Errorf("Expected versions 2 to 3, got %d to %d", diff.From, diff.To)
+	}
+	if len(diff.Fields) != 1 || diff.Fields[0].Field != "brand" || len(diff.Nutrients) != 0 {
+		t.Errorf("Expected only the brand to change, got %+v", diff)
+	}
+	if _, err := s.DiffFoodVersions(t.Context(), owner.ID, v1.ID, 1, 7); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+
+	// 3. Recipe ingredients are matched by family
+	oats := createTestIngredient(t, s, owner, "Oats")
+	milk := createTestIngredient(t, s, owner, "Milk")
+	porridge, err := s.CreateFood(t.Context(), Food{CreatorID: owner.ID, Name: "Porridge", MeasurementUnit: "g", MeasurementAmount: 100,
+		Ingredients: []RecipeItems{{IngredientID: oats.ID, Amount: 100}}})
+	if err != nil {
+		t.Fatalf("CreateFood (recipe) failed: %v", err)
+	}
+	if _, err := s.UpdateFood(t.Context(), porridge.ID, Food{CreatorID: owner.ID, Name: "Porridge", MeasurementUnit: "g", MeasurementAmount: 100,
+		Ingredients: []RecipeItems{{IngredientID: oats.ID, Amount: 50}, {IngredientID: milk.ID, Amount: 200}}}); err != nil {
+		t.Fatalf("UpdateFood (recipe) failed: %v", err)
+	}
+	diff, err = s.DiffFoodVersions(t.Context(), owner.ID, porridge.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffFoodVersions (recipe) failed: %v", err)
+	}
+	if len(diff.Ingredients) != 2 {
+		t.Fatalf("Expected 2 ingredient changes, got %+v", diff.Ingredients)
+	}
+	if c := diff.Ingredients[0]; c.Name != "Oats" || c.From == nil || c.From.Amount != 100 || c.To == nil || c.To.Amount != 50 {
+		t.Errorf("Expected oats to go from 100 to 50, got %+v", c)
+	}
+	if c := diff.Ingredients[1]; c.Name != "Milk" || c.From != nil || c.To == nil {
+		t.Errorf("Expected milk to be added, got %+v", c)
+	}
+
+	// 4. Only the creator may restore a version
+	if _, err := s.RestoreFoodVersion(t.Context(), other.ID, v3.ID, 1); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound restoring another user's private food, got %v", err)
+	}
+	if _, err := s.RestoreFoodVersion(t.Context(), owner.ID, v3.ID, 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+
+	// 5. Restoring copies the old version into a new current one
+	restored, err := s.RestoreFoodVersion(t.Context(), owner.ID, v1.ID, 1)
+	if err != nil {
+		t.Fatalf("RestoreFoodVersion failed: %v", err)
+	}
+	if restored.Version != 4 || !restored.IsCurrent || restored.FamilyID != v1.FamilyID {
+		t.Errorf("Expected version 4 of the family to be current, got version %d current %v", restored.Version, restored.IsCurrent)
+	}
+	got, err := s.GetFood(t.Context(), restored.ID)
+	if err != nil {
+		t.Fatalf("GetFood failed: %v", err)
+	}
+	if got.Name != "Yogurt" || got.Brand != "" || got.Calories != 60 || len(got.Nutrients) != 2 {
+		t.Errorf("Expected the first version's data, got %+v", got)
+	}
+	if len(got.Portions) != 1 || got.Portions[0].Name != "1 pot" {
+		t.Errorf("Expected the first version's portion, got %+v", got.Portions)
+	}
+	diff, err = s.DiffFoodVersions(t.Context(), owner.ID, v1.ID, 1, 4)
+	if err != nil {
+		t.Fatalf("DiffFoodVersions (restored) failed: %v", err)
+	}
+	if len(diff.Fields) != 0 || len(diff.Nutrients) != 0 || len(diff.Ingredients) != 0 {
+		t.Errorf("Expected no changes from version 1, got %+v", diff)
+	}
+	versions, err := s.GetFoodVersions(t.Context(), owner.ID, v1.ID)
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	current := 0
+	for _, v := range versions {
+		if v.IsCurrent {
+			current++
+		}
+	}
+	if len(versions) != 4 || current != 1 {
+		t.Errorf("Expected 4 versions with one current, got %d with %d current", len(versions), current)
+	}
+
+	// 6. Once unpublished, other users can only list and compare versions
+	// they logged
+	if _, err := s.DiffFoodVersions(t.Context(), other.ID, v1.ID, 1, 4); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound diffing another user's private food, got %v", err)
+	}
+	if _, err := s.SetFoodPublic(t.Context(), owner.ID, v1.ID, true); err != nil {
+		t.Fatalf("SetFoodPublic failed: %v", err)
+	}
+	createTestLogEntry(t, s, other, restored, 100, time.Now())
+	if _, err := s.SetFoodPublic(t.Context(), owner.ID, v1.ID, false); err != nil {
+		t.Fatalf("SetFoodPublic failed: %v", err)
+	}
+	listed, err := s.GetFoodVersions(t.Context(), other.ID, v1.ID)
+	if err != nil {
+		t.Fatalf("GetFoodVersions (other) failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Version != 4 {
+		t.Errorf("Expected only the logged version 4, got %d versions", len(listed))
+	}
+	if _, err := s.DiffFoodVersions(t.Context(), other.ID, v1.ID, 4, 4); err != nil {
+		t.Errorf("Expected the logged version to stay comparable, got %v", err)
+	}
+	if _, err := s.DiffFoodVersions(t.Context(), other.ID, v1.ID, 1, 4); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("Expected ErrFoodNotFound for an unlogged version, got %v", err)
+	}
+}
+
+func testFoodVersionConflict(t *testing.T, s Store) {
+	user := createTestUser(t, s)
+	base := createTestIngredient(t, s, user, "Rice")
+	edit := func(id FoodID, name string) (*Food, error) {
+		return s.UpdateFood(t.Context(), id, Food{CreatorID: user.ID, Name: name, MeasurementUnit: "g", MeasurementAmount: 100, Calories: 130})
+	}
+
+	// 1. A second update from the same base conflicts
+	if _, err := edit(base.ID, "White Rice"); err != nil {
+		t.Fatalf("UpdateFood failed: %v", err)
+	}
+	if _, err := edit(base.ID, "Brown Rice"); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	// 2. Of several concurrent updates from the current version, one wins
+	current, err := s.GetFoodVersion(t.Context(), base.ID, 2)
+	if err != nil || current == nil {
+		t.Fatalf("GetFoodVersion failed: %v", err)
+	}
+	errs := make(chan error, 5)
+	for range 5 {
+		go func() {
+			_, err := edit(current.ID, "Basmati Rice")
+			errs <- err
+		}()
+	}
+	wins := 0
+	for range 5 {
+		if err := <-errs; err == nil {
+			wins++
+		} else if !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict, got %v", err)
+		}
+	}
+	if wins != 1 {
+		t.Errorf("Expected exactly one update to win, got %d", wins)
+	}
+	versions, err := s.GetFoodVersions(t.Context(), user.ID, base.ID)
+	if err != nil {
+		t.Fatalf("GetFoodVersions failed: %v", err)
+	}
+	if len(versions) != 3 || !versions[0].IsCurrent || versions[0].Version != 3 || versions[1].IsCurrent || versions[2].IsCurrent {
+		t.Errorf("Expected versions 3, 2 and 1 with only 3 current, got %+v", versions)
+	}
+
+	// 3. The database refuses a second current version
+	store := s.(*sqlStore)
+	_, err = store.db.ExecContext(t.Context(), store.bind("UPDATE foods SET is_current = true WHERE family_id = ?"), base.FamilyID)
+	if err == nil {
+		t.Errorf("Expected the unique index to reject two current versions")
+	}
+}
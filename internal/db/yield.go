@@ -0,0 +1,67 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidYield is returned when a food is written with a negative
+	// yield weight or serving count.
+	ErrInvalidYield = errors.New("yield weight and servings must not be negative")
+	// ErrNoServings is returned when logging a food by servings that does not
+	// say how many servings it makes.
+	ErrNoServings = errors.New("food has no serving count")
+	// ErrNoYieldWeight is returned when logging a food by cooked weight that
+	// does not record its yield weight.
+	ErrNoYieldWeight = errors.New("food has no yield weight")
+)
+
+// AmountForServings converts n servings of the food into an amount in its
+// measurement unit, as stored on a log entry.
+func (f *Food) AmountForServings(n float64) (float64, error) {
+	if f.Servings <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoServings, f.Name)
+	}
+	return amountOf(f, n/f.Servings), nil
+}
+
+// AmountForCookedGrams converts grams of the cooked food into an amount in
+// its measurement unit, as stored on a log entry.
+func (f *Food) AmountForCookedGrams(grams float64) (float64, error) {
+	if f.YieldWeight <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoYieldWeight, f.Name)
+	}
+	return amountOf(f, grams/f.YieldWeight), nil
+}
+
+// applyYield fills in the computed yield fields of a food whose macros and
+// nutrients describe the whole recipe. rawWeight is the total weight of the
+// ingredients, or zero if any of them has an unknown weight.
+func (f *Food) applyYield(rawWeight float64) {
+	f.RawWeight = rawWeight
+	f.CookingLoss = 0
+	if rawWeight > 0 && f.YieldWeight > 0 {
+		f.CookingLoss = 1 - f.YieldWeight/rawWeight
+	}
+
+	f.PerServing, f.Per100g = nil, nil
+	if f.Servings > 0 {
+		f.PerServing = f.scaledNutrition(1 / f.Servings)
+	}
+	if f.YieldWeight > 0 {
+		f.Per100g = f.scaledNutrition(100 / f.YieldWeight)
+	}
+}
+
+func (f *Food) scaledNutrition(factor float64) *Nutrition {
+	var scaled Food
+	scaled.addScaled(f, factor)
+	return &Nutrition{
+		Calories:  scaled.Calories,
+		Protein:   scaled.Protein,
+		Carbs:     scaled.Carbs,
+		Fat:       scaled.Fat,
+		Nutrients: scaled.Nutrients,
+	}
+}
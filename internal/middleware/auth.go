@@ -12,7 +12,7 @@ import (
 )
 
 // RequireAuth middleware ensures the user is authenticated via Bearer Token OR Cookie
-func RequireAuth(next http.Handler) http.Handler {
+func RequireAuth(store db.Store, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var userID db.UserID
 		var err error
@@ -41,7 +41,7 @@ func RequireAuth(next http.Handler) http.Handler {
 			// No token or no header, check Cookie
 			cookie, cErr := r.Cookie(auth.AppSessionCookieName)
 			if cErr == nil && cookie.Value != "" {
-				session, sErr := db.GetSession(cookie.Value)
+				session, sErr := store.GetSession(r.Context(), cookie.Value)
 				if sErr == nil && session != nil {
 					userID = session.UserID
 				}
@@ -54,7 +54,7 @@ func RequireAuth(next http.Handler) http.Handler {
 		}
 
 		// 3. Verify user exists and is active
-		user, err := db.GetUserByID(userID)
+		user, err := store.GetUserByID(r.Context(), userID)
 		if err != nil {
 			// Log error? For now just unauthorized
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
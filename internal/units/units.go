@@ -0,0 +1,128 @@
+// Package units converts quantities between measurement units.
+//
+// Mass, volume and length units convert freely within their own dimension,
+// and mass and volume between each other when a density is known. Any other
+// unit name is a count unit (serving, slice, ...) that only converts to
+// itself.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrIncompatible is returned when a quantity cannot be converted between
+// two units.
+var ErrIncompatible = errors.New("incompatible units")
+
+// Dimension is what a unit measures.
+type Dimension int
+
+const (
+	Count Dimension = iota
+	Mass
+	Volume
+	Length
+)
+
+func (d Dimension) String() string {
+	switch d {
+	case Mass:
+		return "mass"
+	case Volume:
+		return "volume"
+	case Length:
+		return "length"
+	default:
+		return "count"
+	}
+}
+
+// Unit is a parsed unit name.
+type Unit struct {
+	// Name is the canonical name, e.g. "g" for "grams".
+	Name      string
+	Dimension Dimension
+	// base is the size of the unit in grams for mass, millilitres for volume,
+	// centimetres for length and 1 for count units.
+	base float64
+}
+
+var known = []struct {
+	unit    Unit
+	aliases []string
+}{
+	{Unit{"µg", Mass, 0.000001}, []string{"μg", "ug", "mcg", "microgram", "micrograms"}},
+	{Unit{"mg", Mass, 0.001}, []string{"milligram", "milligrams"}},
+	{Unit{"g", Mass, 1}, []string{"gram", "grams", "gr"}},
+	{Unit{"kg", Mass, 1000}, []string{"kilogram", "kilograms", "kgs"}},
+	{Unit{"oz", Mass, 28.349523125}, []string{"ounce", "ounces"}},
+	{Unit{"lb", Mass, 453.59237}, []string{"lbs", "pound", "pounds"}},
+
+	{Unit{"ml", Volume, 1}, []string{"milliliter", "milliliters", "millilitre", "millilitres"}},
+	{Unit{"l", Volume, 1000}, []string{"liter", "liters", "litre", "litres"}},
+	{Unit{"tsp", Volume, 4.92892159375}, []string{"teaspoon", "teaspoons"}},
+	{Unit{"tbsp", Volume, 14.78676478125}, []string{"tablespoon", "tablespoons"}},
+	{Unit{"cup", Volume, 236.5882365}, []string{"cups"}},
+	{Unit{"fl oz", Volume, 29.5735295625}, []string{"floz", "fl. oz", "fluid ounce", "fluid ounces"}},
+
+	{Unit{"mm", Length, 0.1}, []string{"millimeter", "millimeters", "millimetre", "millimetres"}},
+	{Unit{"cm", Length, 1}, []string{"centimeter", "centimeters", "centimetre", "centimetres"}},
+	{Unit{"m", Length, 100}, []string{"meter", "meters", "metre", "metres"}},
+	{Unit{"in", Length, 2.54}, []string{"inch", "inches"}},
+
+	{Unit{"serving", Count, 1}, []string{"servings"}},
+	{Unit{"piece", Count, 1}, []string{"pieces", "pc", "pcs"}},
+	{Unit{"each", Count, 1}, []string{"ea", "item", "items"}},
+	{Unit{"slice", Count, 1}, []string{"slices"}},
+}
+
+var byName = func() map[string]Unit {
+	m := make(map[string]Unit)
+	for _, k := range known {
+		m[k.unit.Name] = k.unit
+		for _, alias := range k.aliases {
+			m[alias] = k.unit
+		}
+	}
+	return m
+}()
+
+// Parse looks up a unit by name or alias, ignoring case and extra spaces.
+// Unrecognised names are count units named after the normalised input.
+func Parse(name string) Unit {
+	name = strings.Join(strings.Fields(strings.ToLower(name)), " ")
+	if u, ok := byName[name]; ok {
+		return u
+	}
+	return Unit{Name: name, Dimension: Count, base: 1}
+}
+
+// Same reports whether two unit names refer to the same unit.
+func Same(a, b string) bool {
+	return Parse(a).Name == Parse(b).Name
+}
+
+// Convert converts amount from one unit to another. density is in grams per
+// millilitre and is only needed to convert between mass and volume; pass 0
+// when it is unknown. Count units only convert to themselves.
+func Convert(amount float64, from, to string, density float64) (float64, error) {
+	f, t := Parse(from), Parse(to)
+	switch {
+	case f.Name == t.Name:
+		return amount, nil
+	case f.Dimension == Count || t.Dimension == Count:
+		return 0, fmt.Errorf("%w: cannot convert %s to %s", ErrIncompatible, f.Name, t.Name)
+	case f.Dimension == t.Dimension:
+		return amount * f.base / t.base, nil
+	case f.Dimension == Length || t.Dimension == Length:
+		return 0, fmt.Errorf("%w: cannot convert %s to %s", ErrIncompatible, f.Name, t.Name)
+	case density <= 0:
+		return 0, fmt.Errorf("%w: converting %s to %s needs a density", ErrIncompatible, f.Name, t.Name)
+	case f.Dimension == Volume:
+		return amount * f.base * density / t.base, nil
+	default:
+		return amount * f.base / density / t.base, nil
+	}
+}
@@ -0,0 +1,81 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		dim  Dimension
+	}{
+		{"g", "g", Mass},
+		{"Grams", "g", Mass},
+		{"  fl   OZ ", "fl oz", Volume},
+		{"tablespoons", "tbsp", Volume},
+		{"Servings", "serving", Count},
+		{"MCG", "µg", Mass},
+		{"Pot", "pot", Count},
+		{"Inches", "in", Length},
+	}
+	for _, tt := range tests {
+		u := Parse(tt.name)
+		if u.Name != tt.want || u.Dimension != tt.dim {
+			t.Errorf("Parse(%q): expected %s (%s), got %s (%s)", tt.name, tt.want, tt.dim, u.Name, u.Dimension)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		from    string
+		to      string
+		density float64
+		want    float64
+	}{
+		{1, "kg", "g", 0, 1000},
+		{1, "lb", "oz", 0, 16},
+		{3, "tsp", "tbsp", 0, 1},
+		{1, "cup", "fl oz", 0, 8},
+		{2, "l", "ml", 0, 2000},
+		{1, "cup", "g", 0.5, 118.29411825},
+		{100, "g", "ml", 2, 50},
+		{250, "mcg", "mg", 0, 0.25},
+		{2, "slices", "slice", 0, 2},
+		{10, "in", "cm", 0, 25.4},
+		{1.8, "m", "mm", 0, 1800},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.amount, tt.from, tt.to, tt.density)
+		if err != nil {
+			t.Errorf("Convert(%v %s to %s): unexpected error %v", tt.amount, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v %s to %s): expected %v, got %v", tt.amount, tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestConvertIncompatible(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		density float64
+	}{
+		{"cup", "g", 0},
+		{"serving", "g", 1},
+		{"slice", "piece", 0},
+		{"cm", "ml", 1},
+		{"g", "in", 1},
+	}
+	for _, tt := range tests {
+		if _, err := Convert(1, tt.from, tt.to, tt.density); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Convert(%s to %s): expected ErrIncompatible, got %v", tt.from, tt.to, err)
+		}
+	}
+}
@@ -158,8 +158,12 @@ export class API {
 
     // --- Foods ---
 
-    async getFoods() {
-        return await this.request('/foods');
+    // Returns { items, next_cursor }; pass next_cursor back as options.cursor
+    // for the following page. options.scope may be 'mine', 'public' or 'all'.
+    async getFoods(options = {}) {
+        const params = new URLSearchParams(options);
+        const query = params.toString();
+        return await this.request(query ? `/foods?${query}` : '/foods');
     }
 
     async createFood(foodData) {
@@ -170,8 +174,46 @@ export class API {
         return await this.request(`/foods/${id}`);
     }
 
-    async updateFood(id, foodData) {
-        return await this.request(`/foods/${id}`, 'PUT', foodData);
+    // baseVersion is the version the edit started from, usually the food's
+    // version field; the server answers 409 if someone has updated it since.
+    async updateFood(id, foodData, baseVersion = foodData.version) {
+        return await this.request(`/foods/${id}`, 'PUT', { ...foodData, base_version: baseVersion });
+    }
+
+    // Copies a food the caller can see into a private food of their own,
+    // recording source_family_id and source_version.
+    async forkFood(id) {
+        return await this.request(`/foods/${id}/fork`, 'POST');
+    }
+
+    // Lists every version of the food's family, newest first.
+    async getFoodVersions(id) {
+        return await this.request(`/foods/${id}/versions`);
+    }
+
+    // Returns the field, nutrient and ingredient changes between two version
+    // numbers; to defaults to the current version and from to the one before.
+    async diffFoodVersions(id, from, to) {
+        const params = new URLSearchParams();
+        if (from) params.set('from', from);
+        if (to) params.set('to', to);
+        const query = params.toString();
+        return await this.request(query ? `/foods/${id}/diff?${query}` : `/foods/${id}/diff`);
+    }
+
+    // Copies an older version into a new current version.
+    async restoreFoodVersion(id, version) {
+        return await this.request(`/foods/${id}/restore?version=${version}`, 'POST');
+    }
+
+    // Makes every version of the caller's food public; fails with 422 when
+    // its nutrition looks implausible or a recipe ingredient is private.
+    async publishFood(id) {
+        return await this.request(`/foods/${id}/publish`, 'POST');
+    }
+
+    async unpublishFood(id) {
+        return await this.request(`/foods/${id}/unpublish`, 'POST');
     }
 
     async deleteFood(id) {
@@ -180,12 +222,15 @@ export class API {
 
     // --- Logs ---
 
-    async getLogs(date) {
-        let url = '/logs';
+    // Returns { items, next_cursor }; options may carry limit, cursor, sort,
+    // from/to (instead of date), meal_tag and include: 'food'.
+    async getLogs(date, options = {}) {
+        const params = new URLSearchParams(options);
         if (date) {
-            url += `?date=${date}`;
+            params.set('date', date);
         }
-        return await this.request(url);
+        const query = params.toString();
+        return await this.request(query ? `/logs?${query}` : '/logs');
     }
 
     async createLog(logData) {
@@ -198,10 +243,83 @@ export class API {
 
     // --- Stats ---
 
-    async getStats(period, date) {
-        const params = new URLSearchParams({ period, date });
+    // period: day, week, month, year, last_7_days or last_30_days. Pass a
+    // null period and options { from, to } for a custom range instead, and
+    // options.nutrients (e.g. 'Vitamin C,Iron') to limit the nutrient totals.
+    async getStats(period, date, options = {}) {
+        const params = new URLSearchParams(options);
+        if (period) {
+            params.set('period', period);
+        }
+        if (date) {
+            params.set('date', date);
+        }
         return await this.request(`/stats?${params.toString()}`);
     }
+
+    // Returns one stats object per day, week or month bucket from..to.
+    // options.nutrients is a comma separated allow-list, as for getStats.
+    async getStatsSeries(from, to, bucket = 'day', options = {}) {
+        const params = new URLSearchParams({ ...options, from, to, bucket });
+        return await this.request(`/stats/series?${params.toString()}`);
+    }
+
+    // Estimated daily energy expenditure over the 28 days up to yesterday, or
+    // options { period, date } / { from, to }. options.rate (e.g. -0.5 kg a
+    // week, or in options.rate_unit) adds a suggested calorie target.
+    async getEnergy(options = {}) {
+        const params = new URLSearchParams(options);
+        return await this.request(`/stats/energy?${params.toString()}`);
+    }
+
+    // --- Profile ---
+
+    async getProfile() {
+        return await this.request('/profile');
+    }
+
+    // profileData: { timezone, week_start }, e.g. 'Europe/Berlin' and 'sunday'
+    async updateProfile(profileData) {
+        return await this.request('/profile', 'PUT', profileData);
+    }
+
+    // --- Goals ---
+
+    async getGoals() {
+        return await this.request('/goals');
+    }
+
+    // goalData: { effective_from, targets: [{ nutrient, unit, min, max }] },
+    // e.g. { nutrient: 'protein', unit: 'g', min: 120 }
+    async setGoal(goalData) {
+        return await this.request('/goals', 'POST', goalData);
+    }
+
+    async deleteGoal(id) {
+        return await this.request(`/goals/${id}`, 'DELETE');
+    }
+
+    // --- Measurements ---
+
+    // options: { kind, period, date } or { kind, from, to }; defaults to all
+    // kinds over the last 30 days. Returns { measurements, trends }.
+    async getMeasurements(options = {}) {
+        const params = new URLSearchParams(options);
+        return await this.request(`/measurements?${params.toString()}`);
+    }
+
+    // measurementData: { kind, value, unit, measured_at }, e.g. { kind: 'weight', value: 80, unit: 'kg' }
+    async createMeasurement(measurementData) {
+        return await this.request('/measurements', 'POST', measurementData);
+    }
+
+    async updateMeasurement(id, measurementData) {
+        return await this.request(`/measurements/${id}`, 'PUT', measurementData);
+    }
+
+    async deleteMeasurement(id) {
+        return await this.request(`/measurements/${id}`, 'DELETE');
+    }
 }
 
 // Export singleton instance
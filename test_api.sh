@@ -26,16 +26,16 @@ check_server
 echo "==================================================="
 echo "Cleanup: Removing existing logs and foods..."
 # Get all logs and delete them
-LOGS=$(curl -s "$BASE_URL/logs")
-LOG_IDS=$(echo $LOGS | jq -r '.[].id // empty')
+LOGS=$(curl -s "$BASE_URL/logs?limit=200")
+LOG_IDS=$(echo $LOGS | jq -r '.items[].id // empty')
 for id in $LOG_IDS; do
     echo "Deleting log $id"
     curl -s -X DELETE "$BASE_URL/logs/$id" > /dev/null
 done
 
 # Get all foods and delete them
-FOODS=$(curl -s "$BASE_URL/foods")
-FOOD_IDS=$(echo $FOODS | jq -r '.[].id // empty')
+FOODS=$(curl -s "$BASE_URL/foods?limit=200")
+FOOD_IDS=$(echo $FOODS | jq -r '.items[].id // empty')
 for id in $FOOD_IDS; do
     echo "Deleting food $id"
     curl -s -X DELETE "$BASE_URL/foods/$id" > /dev/null